@@ -0,0 +1,199 @@
+// Package alert evaluates the alert rules against the stored products after each crawl or watchlist refresh
+// The raised alerts are recorded and delivered through the notifiers
+package alert
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iulianclita/amazonsurfer/crawler"
+	"github.com/iulianclita/amazonsurfer/notify"
+	"github.com/iulianclita/amazonsurfer/store"
+)
+
+// maxDays is the longest period a change rule can look back
+const maxDays = 365
+
+// Engine evaluates the rules and raises the alerts
+type Engine struct {
+	Store    *store.Store
+	Notifier notify.Notifier
+}
+
+// Validate checks a rule and returns the invalid fields
+func Validate(rule store.Rule) error {
+	var errs crawler.ValidationError
+	add := func(field, msg string) {
+		errs = append(errs, crawler.FieldError{Field: field, Message: msg})
+	}
+	if strings.TrimSpace(rule.Name) == "" {
+		add("name", "must not be empty")
+	}
+	switch rule.Kind {
+	case store.RuleCondition, store.RuleNew:
+		if rule.Kind == store.RuleCondition && strings.TrimSpace(rule.Expression) == "" {
+			add("expression", "must not be empty")
+		} else if rule.Expression != "" {
+			if _, err := crawler.ParseFilter(rule.Expression); err != nil {
+				add("expression", err.Error())
+			}
+		}
+	case store.RuleChange:
+		if _, ok := store.Metrics[rule.Metric]; !ok {
+			add("metric", "must be one of price, bsr, reviews or rating")
+		}
+		if rule.Direction != "up" && rule.Direction != "down" && rule.Direction != "any" {
+			add("direction", "must be one of up, down or any")
+		}
+		if rule.Percent <= 0 {
+			add("percent", "must be positive")
+		}
+		if rule.Days < 1 || rule.Days > maxDays {
+			add("days", fmt.Sprintf("must be between 1 and %d", maxDays))
+		}
+	default:
+		add("kind", "must be one of condition, change or new")
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// EvaluateRun evaluates the rules against all the products fetched by a crawl
+func (e *Engine) EvaluateRun(run string) {
+	snaps, err := e.Store.RunProducts(run)
+	if err != nil {
+		log.Println("Error evaluating alerts:", err)
+		return
+	}
+	asins := make([]string, len(snaps))
+	for i, snap := range snaps {
+		asins[i] = snap.Product.ASIN
+	}
+	e.Evaluate(run, asins)
+}
+
+// Evaluate evaluates the enabled rules against the products which were just fetched
+func (e *Engine) Evaluate(run string, asins []string) {
+	rules, err := e.Store.Rules()
+	if err != nil {
+		log.Println("Error evaluating alerts:", err)
+		return
+	}
+	now := time.Now().UTC()
+	for _, asin := range asins {
+		snaps, err := e.Store.Snapshots(asin)
+		if err != nil {
+			log.Println("Error evaluating alerts:", err)
+			continue
+		}
+		for _, rule := range rules {
+			if !rule.Enabled {
+				continue
+			}
+			msg, ok := e.check(rule, asin, snaps, now)
+			if !ok {
+				continue
+			}
+			prod := snaps[len(snaps)-1].Product
+			e.raise(store.Alert{
+				Rule:    rule.ID,
+				Name:    rule.Name,
+				ASIN:    asin,
+				Product: prod.Name,
+				Link:    prod.Link,
+				Run:     run,
+				Message: msg,
+			})
+		}
+	}
+}
+
+// check tells if the rule fires for the product and describes why
+// The snapshots are ordered by time and the last one is the product that was just fetched
+func (e *Engine) check(rule store.Rule, asin string, snaps []store.Snapshot, now time.Time) (string, bool) {
+	if len(snaps) == 0 {
+		return "", false
+	}
+	prod := snaps[len(snaps)-1].Product
+	var f *crawler.Filter
+	if rule.Expression != "" {
+		var err error
+		if f, err = crawler.ParseFilter(rule.Expression); err != nil {
+			log.Printf("Invalid expression of alert rule %s: %s\n", rule.ID, err)
+			return "", false
+		}
+	}
+
+	switch rule.Kind {
+	case store.RuleCondition:
+		// Only the product starting to match raises an alert, not every crawl it keeps matching
+		// The watchlist estimates its snapshots like the last crawl so the derived fields of both compare
+		if !f.Match(&prod) {
+			return "", false
+		}
+		if len(snaps) > 1 {
+			prev := snaps[len(snaps)-2].Product
+			if f.Match(&prev) {
+				return "", false
+			}
+		}
+		return "now matches " + f.String(), true
+	case store.RuleNew:
+		if len(snaps) > 1 || (f != nil && !f.Match(&prod)) {
+			return "", false
+		}
+		if f == nil {
+			return "new product", true
+		}
+		return "new product matching " + f.String(), true
+	case store.RuleChange:
+		c := store.NewHistory(asin, snaps, now).Change(rule.Metric, rule.Days, now)
+		if c == nil {
+			return "", false
+		}
+		if (rule.Direction == "up" && c.Percent < rule.Percent) ||
+			(rule.Direction == "down" && c.Percent > -rule.Percent) ||
+			(rule.Direction == "any" && math.Abs(c.Percent) < rule.Percent) {
+			return "", false
+		}
+		// A lasting change would raise an alert on every crawl so it is raised once per period
+		last, err := e.Store.LastAlert(rule.ID, asin)
+		if err != nil {
+			log.Println("Error evaluating alerts:", err)
+			return "", false
+		}
+		if !last.IsZero() && now.Sub(last) < time.Duration(rule.Days)*24*time.Hour {
+			return "", false
+		}
+		return fmt.Sprintf("%s changed %s%% in %d days (%s to %s)", rule.Metric, signed(c.Percent), rule.Days,
+			strconv.FormatFloat(c.From, 'f', -1, 64), strconv.FormatFloat(c.To, 'f', -1, 64)), true
+	}
+	return "", false
+}
+
+// raise records the alert and delivers it
+func (e *Engine) raise(a store.Alert) {
+	a, err := e.Store.SaveAlert(a)
+	if err != nil {
+		log.Println("Error saving alert:", err)
+		return
+	}
+	if e.Notifier != nil {
+		e.Notifier.Notify(notify.NewEvent(notify.EventAlert, a))
+	}
+}
+
+// signed formats a percentage with its sign
+func signed(f float64) string {
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if f > 0 {
+		return "+" + s
+	}
+	return s
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/iulianclita/amazonsurfer/alert"
+	"github.com/iulianclita/amazonsurfer/crawler"
+	"github.com/iulianclita/amazonsurfer/store"
+)
+
+// rules lists the alert rules on GET, saves one on POST and removes one on DELETE
+// A rule is posted as a form or as a JSON body, posting it with an id updates the existing rule
+func rules(w http.ResponseWriter, r *http.Request) {
+	if db == nil {
+		http.Error(w, "Storage is disabled", http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		rs, err := db.Rules()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, rs)
+	case http.MethodPost:
+		rule, err := parseRule(r)
+		if err != nil {
+			writeErrors(w, err)
+			return
+		}
+		if err := alert.Validate(rule); err != nil {
+			writeErrors(w, err)
+			return
+		}
+		rule, err = db.SaveRule(rule)
+		if err == store.ErrNotFound {
+			http.Error(w, "Rule not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, rule)
+	case http.MethodDelete:
+		err := db.DeleteRule(r.FormValue("id"))
+		if err == store.ErrNotFound {
+			http.Error(w, "Rule not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, "ok")
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// parseRule reads an alert rule from a JSON body or a form
+// Rules are enabled unless the enabled field says otherwise
+func parseRule(r *http.Request) (store.Rule, error) {
+	rule := store.Rule{Enabled: true}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			return rule, crawler.ValidationError{{Field: "body", Message: err.Error()}}
+		}
+		return rule, nil
+	}
+	rule = store.Rule{
+		ID:         r.FormValue("id"),
+		Name:       strings.TrimSpace(r.FormValue("name")),
+		Kind:       r.FormValue("kind"),
+		Expression: strings.TrimSpace(r.FormValue("expression")),
+		Metric:     r.FormValue("metric"),
+		Direction:  r.FormValue("direction"),
+		Enabled:    r.FormValue("enabled") != "false",
+	}
+	if v := r.FormValue("percent"); v != "" {
+		p, err := strconv.ParseFloat(v, 64)
+		if err != nil || math.IsNaN(p) || math.IsInf(p, 0) {
+			return rule, crawler.ValidationError{{Field: "percent", Message: "must be a number"}}
+		}
+		rule.Percent = p
+	}
+	if v := r.FormValue("days"); v != "" {
+		d, err := strconv.Atoi(v)
+		if err != nil {
+			return rule, crawler.ValidationError{{Field: "days", Message: "must be a whole number"}}
+		}
+		rule.Days = d
+	}
+	return rule, nil
+}
+
+// alertsList sends the most recent alerts, 100 of them unless a limit is given
+func alertsList(w http.ResponseWriter, r *http.Request) {
+	if db == nil {
+		http.Error(w, "Storage is disabled", http.StatusNotFound)
+		return
+	}
+	limit, ok := parseLimit(w, r)
+	if !ok {
+		return
+	}
+	as, err := db.Alerts(limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, as)
+}
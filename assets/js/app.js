@@ -1,8 +1,78 @@
 $(document).ready(function() {
     console.log("Application started");
     $('#categories').select2({placeholder: "Select categories"});
+    showEffectiveBounds();
+    $('#search-form').on('input change', 'input, select', showEffectiveBounds);
+    loadWatchlist();
+    showRuleFields();
+    loadRules();
+    loadAlerts();
+    loadSchedules();
+    loadPresets();
+    // A preset id in the page address fills the form with that preset instead of the defaults
+    var preset = new URLSearchParams(window.location.search).get('preset');
+    if (preset) {
+        loadPreset(preset);
+    }
 });
 
+// Criteria with their bound inputs and the number of decimals to display
+var toleranceCriteria = {
+    'price': {min: '#min-price', max: ['#max-price'], decimals: 2},
+    'bsr': {min: '#min-bsr', max: ['#max-bsr'], decimals: 0},
+    'reviews': {min: '#min-reviews', max: ['#max-reviews'], decimals: 0},
+    'rating': {min: '#min-rating', max: ['#max-rating'], decimals: 1},
+    'size': {max: ['#max-length', '#max-width', '#max-height'], decimals: 2},
+    'weight': {max: ['#max-weight'], decimals: 2},
+    'sellers': {max: ['#max-sellers'], decimals: 0},
+    'variations': {max: ['#max-variations'], decimals: 0},
+    'sales': {min: '#min-sales', decimals: 0},
+    'revenue': {min: '#min-revenue', decimals: 2}
+};
+
+function widenBound(bound, criterion, upper) {
+    var value = $('#tolerance-' + criterion).val();
+    var absolute = $('#tolerance-' + criterion + '-type').val() === 'absolute';
+    // Criteria without their own tolerance use the global percentage
+    if (value === '') {
+        value = $('#tolerance').val();
+        absolute = false;
+    }
+    var tol = parseFloat(value) || 0;
+    var widened = absolute ? bound + (upper ? tol : -tol) : bound * (1 + (upper ? tol : -tol) / 100);
+    return Math.max(widened, 0);
+}
+
+// effectiveBound returns the widened bound of an input as text, null when the bound is not set
+function effectiveBound(id, criterion, upper, decimals) {
+    var bound = parseFloat($(id).val());
+    // An unset bound leaves the criterion open on that side so there is nothing to widen
+    if (isNaN(bound)) {
+        return null;
+    }
+    return widenBound(bound, criterion, upper).toFixed(decimals);
+}
+
+function showEffectiveBounds() {
+    $.each(toleranceCriteria, function(criterion, c) {
+        var maxes = $.map(c.max || [], function(id) {
+            var max = effectiveBound(id, criterion, true, c.decimals);
+            return max === null ? 'any' : max;
+        });
+        var anyMax = $.grep(maxes, function(max) { return max !== 'any'; }).length === 0;
+        var min = c.min ? effectiveBound(c.min, criterion, false, c.decimals) : null;
+        var text = 'Effective: any';
+        if (min !== null && !anyMax) {
+            text = 'Effective: ' + min + ' - ' + maxes.join(' x ');
+        } else if (min !== null) {
+            text = 'Effective: at least ' + min;
+        } else if (!anyMax) {
+            text = 'Effective: at most ' + maxes.join(' x ');
+        }
+        $('#effective-' + criterion).html(text);
+    });
+}
+
 function showSearchButton() {
     $('.searching').hide();
     $('#stop-button').hide();
@@ -16,151 +86,437 @@ function showStopButton() {
 }
 
 function resetResultsTable() {
+    $('#report').hide();
     $('#count').html(0);
     $('#count-text').show();
     $('#results tbody tr').remove();
     $('#results').hide();
+    $('#near-misses tbody tr').remove();
+    $('#near-misses').hide();
 }
 
-function validateInput() {
+function showReport(report) {
+    $('#report-summary').html(report.pages + ' pages, ' + report.products + ' products parsed, ' +
+        report.found + ' found, ' + report.errors + ' failed to load' +
+        (report.run && report.products > 0 ? ' &mdash; <a href="export?run=' + report.run + '">Export CSV</a>' : ''));
+    $('#report-expression').text(report.expression ? 'Filter: ' + report.expression : '');
 
-    var isValid = true;
+    // Warn when a field is missing on most products since the page layout probably changed
+    var broken = [];
+    var fields = {};
+    $.each(report.missing, function(field) { fields[field] = true; });
+    $.each(report.invalid, function(field) { fields[field] = true; });
+    $('#report-fields tbody tr').remove();
+    $.each(Object.keys(fields).sort(), function(i, field) {
+        var missing = report.missing[field] || 0;
+        var invalid = report.invalid[field] || 0;
+        if (report.products > 0 && (missing + invalid) / report.products > 0.5) {
+            broken.push(field);
+        }
+        $('#report-fields tbody').append('<tr><td>' + field + '</td><td>' + missing + '</td><td>' + invalid + '</td></tr>');
+    });
+    $('#report-warning').html(broken.length > 0 ? 'Most products are missing: ' + broken.join(', ') + '. The page layout may have changed.' : '');
 
-    if ($('#categories').val().length === 0) {
-        isValid = false;
-        $('.select2-selection').css('border', '2px solid red');
-    } else {
-        $('.select2-selection').css('border', '1px solid black');
-    }
+    $('#report-rejected tbody tr').remove();
+    $.each(report.rejected, function(criterion, count) {
+        $('#report-rejected tbody').append('<tr><td>' + criterion + '</td><td>' + count + '</td></tr>');
+    });
 
-    var minPrice = $('#min-price').val() !== '' ? parseFloat($('#min-price').val()) : -1;
-    var maxPrice = $('#max-price').val() !== '' ? parseFloat($('#max-price').val()) : -1;
+    $('#report').show();
+}
 
-    if (minPrice <= 0) {
-        isValid = false;
-        $('#min-price').addClass('error');
-    } else {
-        $('#min-price').removeClass('error');
+function productThumbnail(product) {
+    if (!product.image) {
+        return '';
     }
+    return '<img class="thumbnail-image" src="' + product.image + '" alt="" />';
+}
 
-    if (maxPrice <= 0) {
-        isValid = false;
-        $('#max-price').addClass('error');
-    } else {
-        $('#max-price').removeClass('error');
+function unknownCriteria(product) {
+    if (!product.unknown || product.unknown.length === 0) {
+        return '';
     }
+    return ' <span class="label label-warning">Unknown: ' + product.unknown.join(', ') + '</span>';
+}
 
-    var minBSR = $('#min-bsr').val() !== '' ? parseInt($('#min-bsr').val()) : -1;
-    var maxBSR = $('#max-bsr').val() !== '' ? parseInt($('#max-bsr').val()) : -1;
-
-    if (minBSR < 0) {
-        isValid = false;
-        $('#min-bsr').addClass('error');
-    } else {
-        $('#min-bsr').removeClass('error');
+function historyLink(product) {
+    if (!product.asin) {
+        return '';
     }
+    return ' <a class="history-link" target="_blank" href="/product?asin=' + product.asin + '"><i class="fa fa-line-chart"></i></a>' +
+        ' <a class="history-link" href="#" title="Watch" onclick="addWatch(\'' + product.asin + '\'); return false;"><i class="fa fa-eye"></i></a>';
+}
+
+function loadWatchlist() {
+    $.getJSON('watchlist', function(items) {
+        $('#watchlist-table tbody tr').remove();
+        $.each(items, function(i, item) {
+            $('#watchlist-table tbody').append(watchRow(item));
+        });
+    });
+}
 
-    if (maxBSR <= 0) {
-        isValid = false;
-        $('#max-bsr').addClass('error');
-    } else {
-        $('#max-bsr').removeClass('error');
+function watchRow(item) {
+    var checked = item.checked && item.checked.indexOf('0001-') !== 0 ? new Date(item.checked).toLocaleString() : 'never';
+    var changes = $.map(item.changes || [], function(c) {
+        return c.field + ': ' + c.from + ' &rarr; ' + c.to;
+    }).join('<br/>');
+    if (item.error) {
+        changes = '<span class="text-danger">' + item.error + '</span>';
     }
+    var name = item.name || item.asin;
+    return '<tr' + (item.changed ? ' class="warning"' : '') + '><td><a target="_blank" href="/product?asin=' + item.asin + '">' + name + '</a></td>' +
+        '<td>' + checked + '</td><td>' + changes + '</td>' +
+        '<td><button type="button" class="btn btn-xs btn-default" onclick="removeWatch(\'' + item.asin + '\')">Remove</button></td></tr>';
+}
 
-    var minReviews = $('#min-reviews').val() !== '' ? parseInt($('#min-reviews').val()) : -1;
-    var maxReviews = $('#max-reviews').val() !== '' ? parseInt($('#max-reviews').val()) : -1;
+function addWatch(asin) {
+    $.ajax({
+        type: 'POST',
+        url: 'watchlist',
+        data: {asin: $.trim(asin).toUpperCase()},
+        success: function() {
+            $('#watch-asin').val('');
+            loadWatchlist();
+        },
+        error: function(xhr) {
+            alert(xhr.responseText);
+        }
+    });
+}
 
-    if (minReviews < 0) {
-        isValid = false;
-        $('#min-reviews').addClass('error');
-    } else {
-        $('#min-reviews').removeClass('error');
-    }
+function removeWatch(asin) {
+    $.ajax({
+        type: 'DELETE',
+        url: 'watchlist?asin=' + asin,
+        success: loadWatchlist,
+        error: function(xhr) {
+            alert(xhr.responseText);
+        }
+    });
+}
 
-    if (maxReviews <= 0) {
-        isValid = false;
-        $('#max-reviews').addClass('error');
-    } else {
-        $('#max-reviews').removeClass('error');
-    }
+function productRow(product) {
+    return '<tr><td>' + productThumbnail(product) + '<a target="_blank" href="' + product.link + '">' + product.name + '</a>' +
+        unknownCriteria(product) + historyLink(product) + '</td><td>' + product.sales + '</td><td>' + product.revenue.toFixed(2) + '</td>' +
+        '<td>' + product.sizeTier + '</td><td>' + product.profit.toFixed(2) + '</td><td>' + product.margin.toFixed(2) + '%</td>' +
+        '<td>' + product.score.toFixed(1) + '</td></tr>';
+}
 
-    var maxLength = $('#max-length').val() !== '' ? parseFloat($('#max-length').val()) : -1;
+// Replace the results with the final list ranked by score
+function showRanking(products) {
+    $('#results tbody tr').remove();
+    $.each(products || [], function(i, product) {
+        $('#results tbody').append(productRow(product));
+    });
+}
 
-    if (maxLength <= 0) {
-        isValid = false;
-        $('#max-length').addClass('error');
-    } else {
-        $('#max-length').removeClass('error');
-    }
+function nearMissRow(product) {
+    var reasons = $.map(product.rejections, function(rej) {
+        return rej.criterion + ' is ' + rej.actual + ' (allowed ' + rej.allowed + ')';
+    });
+    return '<tr><td>' + productThumbnail(product) + '<a target="_blank" href="' + product.link + '">' + product.name + '</a></td>' +
+        '<td>' + reasons.join('<br/>') + '</td></tr>';
+}
 
-    var maxWidth = $('#max-width').val() !== '' ? parseFloat($('#max-width').val()) : -1;
+function clearErrors() {
+    $('#search-form .error').removeClass('error');
+    $('.select2-selection').css('border', '1px solid black');
+    $('#errors').html('').hide();
+}
 
-    if (maxWidth <= 0) {
-        isValid = false;
-        $('#max-width').addClass('error');
-    } else {
-        $('#max-width').removeClass('error');
-    }
+// Highlight the fields the server rejected and list the reasons
+function showErrors(errors) {
+    var messages = $.map(errors, function(err) {
+        if (err.field === 'categories') {
+            $('.select2-selection').css('border', '2px solid red');
+        } else if (err.field) {
+            $('#' + err.field).addClass('error');
+        }
+        return '<li>' + (err.field ? err.field + ' ' : '') + err.message + '</li>';
+    });
+    $('#errors').html('<ul>' + messages.join('') + '</ul>').show();
+}
 
-    var maxHeight = $('#max-height').val() !== '' ? parseFloat($('#max-height').val()) : -1;
+// Show only the inputs used by the selected kind of rule
+function showRuleFields() {
+    var kind = $('#rule-kind').val();
+    $('#rule-form .rule-condition, #rule-form .rule-change, #rule-form .rule-new').hide();
+    $('#rule-form .rule-' + kind).show();
+}
 
-    if (maxHeight <= 0) {
-        isValid = false;
-        $('#max-height').addClass('error');
-    } else {
-        $('#max-height').removeClass('error');
+function ruleText(rule) {
+    switch (rule.kind) {
+    case 'condition':
+        return 'starts matching ' + rule.expression;
+    case 'change':
+        return rule.metric + ' goes ' + (rule.direction === 'any' ? 'up or down' : rule.direction) + ' by ' +
+            rule.percent + '% in ' + rule.days + ' days';
+    default:
+        return 'new product' + (rule.expression ? ' matching ' + rule.expression : '');
     }
+}
 
-    var maxWeight = $('#max-weight').val() !== '' ? parseFloat($('#max-weight').val()) : -1;
+function loadRules() {
+    $.getJSON('rules', function(rules) {
+        $('#rules-table tbody tr').remove();
+        $.each(rules, function(i, rule) {
+            $('#rules-table tbody').append('<tr><td>' + rule.name + '</td><td>' + ruleText(rule) + '</td>' +
+                '<td><button type="button" class="btn btn-xs btn-default" onclick="removeRule(\'' + rule.id + '\')">Remove</button></td></tr>');
+        });
+    });
+}
 
-    if (maxWeight <= 0) {
-        isValid = false;
-        $('#max-weight').addClass('error');
-    } else {
-        $('#max-weight').removeClass('error');
-    }
+function addRule() {
+    // Only send the inputs used by the selected kind of rule
+    var data = $.grep($('#rule-form').serializeArray(), function(field) {
+        return $('#rule-' + field.name).is(':visible');
+    });
+    $.ajax({
+        type: 'POST',
+        url: 'rules',
+        data: data,
+        success: function() {
+            $('#rule-name').val('');
+            $('#rule-expression').val('');
+            loadRules();
+        },
+        error: function(xhr) {
+            if (xhr.responseJSON && xhr.responseJSON.errors) {
+                alert($.map(xhr.responseJSON.errors, function(err) { return err.field + ' ' + err.message; }).join('\n'));
+            } else {
+                alert(xhr.responseText);
+            }
+        }
+    });
+}
 
-    var tolerance = $('#tolerance').val() !== '' ? parseFloat($('#tolerance').val()) : -1;
+function removeRule(id) {
+    $.ajax({
+        type: 'DELETE',
+        url: 'rules?id=' + id,
+        success: loadRules,
+        error: function(xhr) {
+            alert(xhr.responseText);
+        }
+    });
+}
 
-    if (tolerance < 0 || tolerance > 10) {
-        isValid = false;
-        $('#tolerance').addClass('error');
-    } else {
-        $('#tolerance').removeClass('error');
-    }
+function loadAlerts() {
+    $.getJSON('alerts', {limit: 50}, function(alerts) {
+        $('#alerts-table tbody tr').remove();
+        $.each(alerts, function(i, a) {
+            $('#alerts-table tbody').append('<tr><td>' + new Date(a.time).toLocaleString() + '</td><td>' + a.name + '</td>' +
+                '<td><a target="_blank" href="/product?asin=' + a.asin + '">' + (a.product || a.asin) + '</a></td><td>' + a.message + '</td></tr>');
+        });
+    });
+}
 
-    if (minPrice >= maxPrice) {
-        isValid = false;
-        $('#min-price').addClass('error');
-        $('#max-price').addClass('error');
-    } else {
-        if (minPrice > 0 && maxPrice > 0) {
-            $('#min-price').removeClass('error');
-            $('#max-price').removeClass('error');
+// Fill the search form with saved search input, e.g. to edit a scheduled search
+function fillForm(options) {
+    $('#search-form').find('input, select, textarea').each(function() {
+        var name = $(this).attr('name');
+        if (!name || name === 'categories') {
+            return;
+        }
+        if ($(this).is(':checkbox')) {
+            $(this).prop('checked', !!options[name]);
+        } else {
+            $(this).val(options[name] ? options[name][0] : '');
         }
+    });
+    $('#categories').val(options.categories || []).trigger('change');
+    showEffectiveBounds();
+}
+
+// Schedules by id so they can be edited
+var schedules = {};
+
+function loadSchedules() {
+    $.getJSON('schedules', function(list) {
+        schedules = {};
+        $('#schedules-table tbody tr').remove();
+        $.each(list, function(i, sch) {
+            schedules[sch.id] = sch;
+            $('#schedules-table tbody').append(scheduleRow(sch));
+        });
+    });
+}
+
+function scheduleRow(sch) {
+    var next = sch.paused ? 'Paused' : (sch.next ? new Date(sch.next).toLocaleString() : 'Never');
+    var last = 'Never';
+    if (sch.running) {
+        last = 'Running';
+    } else if (sch.lastRun) {
+        last = '<a target="_blank" href="/runs?id=' + sch.lastRun + '">' + new Date(sch.lastStarted).toLocaleString() + '</a>' +
+            ' (<a href="/export?run=' + sch.lastRun + '">CSV</a>)' +
+            (sch.lastError ? ' <span class="text-danger">' + sch.lastError + '</span>' : '');
     }
+    return '<tr><td>' + sch.name + '</td><td><code>' + sch.cron + '</code></td><td>' + next + '</td><td>' + last + '</td><td>' +
+        '<button type="button" class="btn btn-xs btn-default" onclick="runSchedule(\'' + sch.id + '\')">Run now</button> ' +
+        '<button type="button" class="btn btn-xs btn-default" onclick="pauseSchedule(\'' + sch.id + '\', ' + !sch.paused + ')">' + (sch.paused ? 'Resume' : 'Pause') + '</button> ' +
+        '<button type="button" class="btn btn-xs btn-default" onclick="editSchedule(\'' + sch.id + '\')">Edit</button> ' +
+        '<button type="button" class="btn btn-xs btn-default" onclick="removeSchedule(\'' + sch.id + '\')">Remove</button></td></tr>';
+}
 
-    if (minBSR >= maxBSR) {
-        isValid = false;
-        $('#min-bsr').addClass('error');
-        $('#max-bsr').addClass('error');
-    } else {
-        if (minBSR >= 0 && maxBSR > 0) {
-            $('#min-bsr').removeClass('error');
-            $('#max-bsr').removeClass('error');
-        }
+// Save the search form along with the schedule, a new schedule unless one is being edited
+function saveSchedule() {
+    clearErrors();
+    var data = $('#search-form').serializeArray().concat($('#schedule-form').serializeArray());
+    if ($('#schedule-id').val() && schedules[$('#schedule-id').val()].paused) {
+        data.push({name: 'schedule-paused', value: 'true'});
     }
+    $.ajax({
+        type: 'POST',
+        url: 'schedules',
+        data: data,
+        success: function() {
+            resetScheduleForm();
+            loadSchedules();
+        },
+        error: function(xhr) {
+            if (xhr.responseJSON && xhr.responseJSON.errors) {
+                showErrors(xhr.responseJSON.errors);
+            } else {
+                alert(xhr.responseText);
+            }
+        }
+    });
+}
+
+function resetScheduleForm() {
+    $('#schedule-id').val('');
+    $('#schedule-name').val('');
+    $('#schedule-cron').val('');
+    $('#schedule-button').text('Schedule this search');
+}
+
+// Load a schedule in the forms, saving it then updates the schedule
+function editSchedule(id) {
+    var sch = schedules[id];
+    fillForm(sch.options);
+    $('#schedule-id').val(sch.id);
+    $('#schedule-name').val(sch.name);
+    $('#schedule-cron').val(sch.cron);
+    $('#schedule-button').text('Save schedule');
+    $('html, body').animate({scrollTop: 0});
+}
 
-    if (minReviews >= maxReviews) {
-        isValid = false;
-        $('#min-reviews').addClass('error');
-        $('#max-reviews').addClass('error');
-    } else {
-        if (minReviews >= 0 && maxReviews > 0) {
-            $('#min-reviews').removeClass('error');
-            $('#max-reviews').removeClass('error');
+function pauseSchedule(id, paused) {
+    $.ajax({
+        type: 'POST',
+        url: 'schedules/pause',
+        data: {id: id, paused: paused},
+        success: loadSchedules,
+        error: function(xhr) {
+            alert(xhr.responseText);
         }
+    });
+}
+
+function runSchedule(id) {
+    $.ajax({
+        type: 'POST',
+        url: 'schedules/run',
+        data: {id: id},
+        success: loadSchedules,
+        error: function(xhr) {
+            if (xhr.responseJSON && xhr.responseJSON.errors) {
+                alert($.map(xhr.responseJSON.errors, function(err) { return err.field + ' ' + err.message; }).join('\n'));
+            } else {
+                alert(xhr.responseText);
+            }
+        }
+    });
+}
+
+function removeSchedule(id) {
+    $.ajax({
+        type: 'DELETE',
+        url: 'schedules?id=' + id,
+        success: function() {
+            if ($('#schedule-id').val() === id) {
+                resetScheduleForm();
+            }
+            loadSchedules();
+        },
+        error: function(xhr) {
+            alert(xhr.responseText);
+        }
+    });
+}
+
+// Presets by id so the selected one can be updated
+var presets = {};
+
+function loadPresets() {
+    $.getJSON('presets', function(list) {
+        var selected = $('#preset-list').val();
+        presets = {};
+        $('#preset-list option[value!=""]').remove();
+        $.each(list, function(i, p) {
+            presets[p.id] = p;
+            $('#preset-list').append($('<option>').val(p.id).text(p.name));
+        });
+        $('#preset-list').val(presets[selected] ? selected : '');
+    });
+}
+
+function loadPreset(id) {
+    if (!id) {
+        return;
     }
+    $.getJSON('presets', {id: id}, function(p) {
+        clearErrors();
+        fillForm(p.options);
+        $('#preset-list').val(p.id);
+        $('#preset-name').val(p.name);
+    }).fail(function(xhr) {
+        alert(xhr.responseText);
+    });
+}
 
-    return isValid;
-}
\ No newline at end of file
+// Save the search form as a preset, the selected preset is updated when the name was kept
+function savePreset() {
+    clearErrors();
+    var data = $('#search-form').serializeArray().concat($('#preset-form').serializeArray());
+    var selected = presets[$('#preset-list').val()];
+    if (selected && selected.name === $('#preset-name').val().trim()) {
+        data.push({name: 'preset-id', value: selected.id});
+    }
+    $.ajax({
+        type: 'POST',
+        url: 'presets',
+        data: data,
+        success: function(p) {
+            $('#preset-list').append($('<option>').val(p.id).text(p.name)).val(p.id);
+            loadPresets();
+        },
+        error: function(xhr) {
+            if (xhr.responseJSON && xhr.responseJSON.errors) {
+                showErrors(xhr.responseJSON.errors);
+            } else {
+                alert(xhr.responseText);
+            }
+        }
+    });
+}
+
+function removePreset(id) {
+    if (!id) {
+        return;
+    }
+    $.ajax({
+        type: 'DELETE',
+        url: 'presets?id=' + id,
+        success: function() {
+            $('#preset-name').val('');
+            $('#preset-list').val('');
+            loadPresets();
+        },
+        error: function(xhr) {
+            alert(xhr.responseText);
+        }
+    });
+}
@@ -0,0 +1,67 @@
+// Chart size in pixels
+var chartWidth = 500;
+var chartHeight = 200;
+var chartPadding = 40;
+
+function showHistory(history) {
+    // The name and the link were scraped so they are set as text and attribute, never as HTML
+    $('#product-name').text(history.name || history.asin);
+    if (history.link) {
+        $('#product-link').empty().append($('<a>').attr({target: '_blank', href: history.link}).text(history.asin));
+    }
+    showTrends(history.trends);
+    // The BSR chart is upside down since a lower rank is better
+    drawChart('#chart-price', history.points, 'price', false);
+    drawChart('#chart-bsr', history.points, 'bsr', true);
+    drawChart('#chart-reviews', history.points, 'reviews', false);
+    drawChart('#chart-rating', history.points, 'rating', false);
+}
+
+function changeText(change) {
+    if (!change) {
+        return '-';
+    }
+    var sign = change.delta > 0 ? '+' : '';
+    return sign + change.delta + ' (' + sign + change.percent + '%)';
+}
+
+function showTrends(trends) {
+    $('#trends tbody tr').remove();
+    $.each(trends, function(i, t) {
+        $('#trends tbody').append('<tr><td>' + t.days + ' days</td><td>' + changeText(t.price) + '</td><td>' +
+            changeText(t.bsr) + '</td><td>' + changeText(t.reviews) + '</td><td>' + changeText(t.rating) + '</td></tr>');
+    });
+}
+
+// Draw the values of a field as a SVG line, points where the field was not found are skipped
+function drawChart(id, points, field, inverted) {
+    var values = $.grep(points, function(p) { return p[field] !== null; });
+    if (values.length === 0) {
+        $(id).html('<p class="text-muted">No data</p>');
+        return;
+    }
+    var times = $.map(values, function(p) { return new Date(p.time).getTime(); });
+    var ys = $.map(values, function(p) { return p[field]; });
+    var minX = Math.min.apply(null, times), maxX = Math.max.apply(null, times);
+    var minY = Math.min.apply(null, ys), maxY = Math.max.apply(null, ys);
+    var x = function(t) {
+        return chartPadding + (maxX === minX ? 0.5 : (t - minX) / (maxX - minX)) * (chartWidth - 2 * chartPadding);
+    };
+    var y = function(v) {
+        var r = maxY === minY ? 0.5 : (v - minY) / (maxY - minY);
+        if (!inverted) {
+            r = 1 - r;
+        }
+        return chartPadding / 2 + r * (chartHeight - chartPadding);
+    };
+    var line = $.map(values, function(p, i) { return x(times[i]) + ',' + y(ys[i]); }).join(' ');
+    var dots = $.map(values, function(p, i) {
+        return '<circle cx="' + x(times[i]) + '" cy="' + y(ys[i]) + '" r="3"><title>' +
+            new Date(p.time).toLocaleString() + ': ' + ys[i] + '</title></circle>';
+    }).join('');
+    var top = inverted ? minY : maxY, bottom = inverted ? maxY : minY;
+    $(id).html('<svg width="' + chartWidth + '" height="' + chartHeight + '">' +
+        '<text x="0" y="' + (chartPadding / 2 + 4) + '">' + top + '</text>' +
+        '<text x="0" y="' + (chartHeight - chartPadding / 2 + 4) + '">' + bottom + '</text>' +
+        '<polyline points="' + line + '" />' + dots + '</svg>');
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/iulianclita/amazonsurfer/store"
+)
+
+// commandUsage describes the subcommands working on the stored runs
+const commandUsage = `Usage:
+  amazonsurfer [flags] runs               list the stored runs
+  amazonsurfer [flags] diff <from> <to>   compare the products found by two runs`
+
+// runSource is where the commands read the stored runs from
+// The database file can only be open in one process so while the server runs the commands go through its API
+type runSource interface {
+	Runs() ([]store.Run, error)
+	Diff(from, to string) (store.Diff, error)
+	// Export writes the CSV export of a run, locale is a language tag like de-DE
+	Export(w io.Writer, id, columns, locale string, found bool) error
+}
+
+// localSource reads the runs from the database file
+type localSource struct {
+	*store.Store
+}
+
+// Export writes the CSV export of a run from the database file
+func (s localSource) Export(w io.Writer, id, columns, locale string, found bool) error {
+	l, err := store.ParseLocale(locale)
+	if err != nil {
+		return err
+	}
+	return exportRun(s.Store, w, id, splitColumns(columns), l, found)
+}
+
+// serverSource reads the runs through the HTTP API of the running server
+type serverSource struct {
+	base   string
+	client *http.Client
+}
+
+// newServerSource makes the source of a server, a bare port like 1234 is a server on localhost
+func newServerSource(server string) serverSource {
+	if !strings.Contains(server, "://") {
+		server = "http://localhost:" + server
+	}
+	return serverSource{base: strings.TrimSuffix(server, "/"), client: &http.Client{Timeout: time.Minute}}
+}
+
+// get sends a request to the API, the error holds the message of the server when it does not answer 200
+func (s serverSource) get(path string, query url.Values) (*http.Response, error) {
+	res, err := s.client.Get(s.base + path + "?" + query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("the database is locked by the server and %s cannot be reached: %s", s.base, err)
+	}
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return nil, fmt.Errorf("%s", strings.TrimSpace(string(msg)))
+	}
+	return res, nil
+}
+
+// getJSON sends a request to the API and decodes the answer
+func (s serverSource) getJSON(path string, query url.Values, v interface{}) error {
+	res, err := s.get(path, query)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// Runs lists the runs with GET /runs
+func (s serverSource) Runs() ([]store.Run, error) {
+	var rs []store.Run
+	err := s.getJSON("/runs", nil, &rs)
+	return rs, err
+}
+
+// Diff compares two runs with GET /diff
+func (s serverSource) Diff(from, to string) (store.Diff, error) {
+	var d store.Diff
+	err := s.getJSON("/diff", url.Values{"from": {from}, "to": {to}}, &d)
+	return d, err
+}
+
+// Export downloads the CSV export of a run with GET /export
+func (s serverSource) Export(w io.Writer, id, columns, locale string, found bool) error {
+	if _, err := store.ParseLocale(locale); err != nil {
+		return err
+	}
+	query := url.Values{"run": {id}, "columns": {columns}, "locale": {locale}}
+	if found {
+		query.Set("found", "true")
+	}
+	res, err := s.get("/export", query)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, err = io.Copy(w, res.Body)
+	return err
+}
+
+// command runs a subcommand instead of starting the server and returns the exit code
+func command(src runSource, args []string) int {
+	if src == nil {
+		fmt.Fprintln(os.Stderr, "Storage is disabled, set a database file with -db")
+		return 1
+	}
+	switch {
+	case args[0] == "runs" && len(args) == 1:
+		rs, err := src.Runs()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		for _, run := range rs {
+			fmt.Printf("%s  started %s  %d products, %d found\n", run.ID, run.Started.Local().Format("2006-01-02 15:04"), run.Report.Products, run.Report.Found)
+		}
+		return 0
+	case args[0] == "diff" && len(args) == 3:
+		d, err := src.Diff(args[1], args[2])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot compare runs %s and %s: %s\n", args[1], args[2], err)
+			return 1
+		}
+		if err := d.WriteReport(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	default:
+		fmt.Fprintln(os.Stderr, commandUsage)
+		return 2
+	}
+}
+
+// exportCommand writes the CSV export of a run to the standard output and returns the exit code
+func exportCommand(src runSource, id, columns, locale string, found bool) int {
+	if src == nil {
+		fmt.Fprintln(os.Stderr, "Storage is disabled, set a database file with -db")
+		return 1
+	}
+	if err := src.Export(os.Stdout, id, columns, locale, found); err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot export run %s: %s\n", id, err)
+		return 1
+	}
+	return 0
+}
@@ -2,7 +2,7 @@ package crawler
 
 import (
 	"errors"
-	"log"
+	"fmt"
 	"net/url"
 	"path"
 	"strconv"
@@ -333,7 +333,7 @@ func (cat *category) getLinks() ([]string, error) {
 		link := path.Join(base, sub.slug, "zgbs", cat.slug, sid)
 		u, err := url.Parse(link)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
 		u.Scheme = "https"
 		link = u.String()
@@ -348,26 +348,38 @@ func filterCategories(catIDs []string) ([]category, error) {
 	if len(catIDs) == 0 {
 		return nil, nil
 	}
-	cats := make([]category, len(catIDs))
-	i := 0
+	cats := make([]category, 0, len(catIDs))
 	for _, id := range catIDs {
-		id, err := strconv.ParseUint(id, 10, 8)
+		catID, err := strconv.ParseUint(id, 10, 8)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("must be valid category ids, got %q", id)
 		}
-		catID := uint8(id)
+		found := false
 		for _, c := range categories {
-			if catID == uint8(c.id) {
-				cats[i] = c
-				i++
+			if uint8(catID) == uint8(c.id) {
+				cats = append(cats, c)
+				found = true
 				break
 			}
 		}
+		if !found {
+			return nil, fmt.Errorf("has an unknown category id %d", catID)
+		}
 	}
 
 	return cats, nil
 }
 
+// categorySlug returns the slug of a main category by its name, the default one for unknown names
+func categorySlug(name string) string {
+	for _, cat := range categories {
+		if cat.name == name {
+			return cat.slug
+		}
+	}
+	return defaultCategory
+}
+
 // GetCategories fetches a list of all main categories in a map
 // After we load this map in template to be rendered as a HTML select
 func GetCategories() map[uint8]string {
@@ -13,123 +13,50 @@ import (
 )
 
 // Crawler scrapes Amazon website for products
+// A crawler runs one crawl at a time, concurrent crawls need a crawler each
 type Crawler struct {
 	opts    options
 	Done    chan struct{}
 	conn    *websocket.Conn
 	Timeout time.Duration
+	report  Report
+	results []Product
+	// DefaultFilter is used when a search does not come with its own filter expression
+	DefaultFilter *Filter
+	// SalesCurves estimate the monthly sales from the rank, the built-in curve is used when nil
+	SalesCurves SalesCurves
+	// FeeTable holds the FBA fees used to compute the profit, the built-in table is used when nil
+	FeeTable *FeeTable
+	// Recorder saves the runs and the fetched products, nothing is saved when nil
+	Recorder Recorder
+	// Hooks are called when a product is found and when the crawl is over
+	Hooks Hooks
+	// runID identifies the current crawl and values hold the search input it was started with
+	runID  string
+	values url.Values
+	// wg waits for all the scraping goroutines and mu guards the report they update
+	wg sync.WaitGroup
+	mu sync.Mutex
 }
 
-// options holds parameters necessary to filter products
-type options struct {
-	categories []category
-	minPrice   float64
-	maxPrice   float64
-	minBSR     uint32
-	maxBSR     uint32
-	minReviews uint32
-	maxReviews uint32
-	maxLength  float64
-	maxWidth   float64
-	maxHeight  float64
-	maxWeight  float64
-	tolerance  float64
-}
-
-// wg waits for all goroutines to finish
-var wg sync.WaitGroup
-
-// MapOptions extracts the request data and maps the input to Crawler options
-// This way the crawler knows which options to use when filtering products
-func (crw *Crawler) MapOptions(r *http.Request) error {
-
-	minPrice, err := strconv.ParseFloat(r.FormValue("min-price"), 64)
-	if err != nil {
-		return err
-	}
-
-	maxPrice, err := strconv.ParseFloat(r.FormValue("max-price"), 64)
-	if err != nil {
-		return err
-	}
-
-	minBSR, err := strconv.ParseUint(r.FormValue("min-bsr"), 10, 32)
-	if err != nil {
-		return err
-	}
-
-	maxBSR, err := strconv.ParseUint(r.FormValue("max-bsr"), 10, 32)
-	if err != nil {
-		return err
-	}
-
-	minReviews, err := strconv.ParseUint(r.FormValue("min-reviews"), 10, 32)
-	if err != nil {
-		return err
-	}
-
-	maxReviews, err := strconv.ParseUint(r.FormValue("max-reviews"), 10, 32)
-	if err != nil {
-		return err
-	}
-
-	maxLength, err := strconv.ParseFloat(r.FormValue("max-length"), 64)
-	if err != nil {
-		return err
-	}
-
-	maxWidth, err := strconv.ParseFloat(r.FormValue("max-width"), 64)
-	if err != nil {
-		return err
-	}
-
-	maxHeight, err := strconv.ParseFloat(r.FormValue("max-height"), 64)
-	if err != nil {
-		return err
-	}
-
-	maxWeight, err := strconv.ParseFloat(r.FormValue("max-weight"), 64)
-	if err != nil {
-		return err
-	}
-
-	tolerance, err := strconv.ParseFloat(r.FormValue("tolerance"), 64)
-	if err != nil {
-		return err
-	}
-
-	cats, err := filterCategories(r.Form["categories"])
-	if err != nil {
-		return err
-	}
-	// We save these options on the crawler
-	crw.opts.categories = cats
-	crw.opts.minPrice = minPrice
-	crw.opts.maxPrice = maxPrice
-	crw.opts.minBSR = uint32(minBSR)
-	crw.opts.maxBSR = uint32(maxBSR)
-	crw.opts.minReviews = uint32(minReviews)
-	crw.opts.maxReviews = uint32(maxReviews)
-	crw.opts.maxLength = maxLength
-	crw.opts.maxWidth = maxWidth
-	crw.opts.maxHeight = maxHeight
-	crw.opts.maxWeight = maxWeight
-	crw.opts.tolerance = tolerance
-
-	return nil
+// categoryLink is a subcategory page link along with the main category it belongs to
+type categoryLink struct {
+	link string
+	cat  category
 }
 
 // getLinks delegates work to the function getLinks mentioned above
 // The crawler must a have a list of all links waiting to be scrapped
 // Every category comes with its links and are all accumulated here
-func (crw *Crawler) getLinks() []string {
+// The links keep their category so the products can be estimated with the category sales curve
+func (crw *Crawler) getLinks() []categoryLink {
 	// Calculate the total length of the links slice
 	// This way it is very efficient because we make 1 allocation only
 	var length uint16
 	for _, cat := range crw.opts.categories {
 		length += uint16(len(cat.subs))
 	}
-	links := make([]string, length)
+	links := make([]categoryLink, 0, length)
 	// We extract all links from every category and merge them in the final slice
 	for _, cat := range crw.opts.categories {
 		clinks, err := cat.getLinks()
@@ -137,8 +64,8 @@ func (crw *Crawler) getLinks() []string {
 			log.Println(err)
 			continue
 		}
-		for i, cl := range clinks {
-			links[i] = cl
+		for _, cl := range clinks {
+			links = append(links, categoryLink{link: cl, cat: cat})
 		}
 	}
 	return links
@@ -147,8 +74,8 @@ func (crw *Crawler) getLinks() []string {
 // scrape extracts all product links from a certain category
 // When it finds suitable products it sends them through the prods channel
 // and the main goroutine sends them in the frontend
-func (crw *Crawler) scrape(link string, prods chan<- Product, client *http.Client) {
-	defer wg.Done()
+func (crw *Crawler) scrape(link string, cat category, prods chan<- Product, client *http.Client) {
+	defer crw.wg.Done()
 	// Start from first page
 	page := 1
 	// Loop through all subcategory pages
@@ -163,7 +90,8 @@ func (crw *Crawler) scrape(link string, prods chan<- Product, client *http.Clien
 		req, err := http.NewRequest(http.MethodGet, plink, nil)
 
 		if err != nil {
-			log.Fatal(err)
+			log.Println(err)
+			return
 		}
 		// Set proper headers to simulate a request coming from a real browser
 		req.Header.Set("Accept", headers["Accept"])
@@ -174,6 +102,7 @@ func (crw *Crawler) scrape(link string, prods chan<- Product, client *http.Clien
 		res, err := client.Do(req)
 		if err != nil {
 			log.Println(err)
+			return
 		}
 		// Exit this goroutine when there are no more pages to scrape
 		if res.StatusCode != http.StatusOK {
@@ -181,10 +110,12 @@ func (crw *Crawler) scrape(link string, prods chan<- Product, client *http.Clien
 		}
 		// Parse the DOM
 		doc, err := goquery.NewDocumentFromReader(res.Body)
+		res.Body.Close()
 		if err != nil {
 			log.Println(err)
+			return
 		}
-		defer res.Body.Close()
+		crw.recordPage()
 		// Hold the product links in a set like structure
 		// This way we make sure that no duplicate links are inserted
 		prodLinks := make(map[string]bool)
@@ -208,9 +139,25 @@ func (crw *Crawler) scrape(link string, prods chan<- Product, client *http.Clien
 						p, err := getProduct(link, client, crw.Done)
 						if err != nil {
 							log.Println(err)
+							crw.recordError()
 						} else {
+							// The score, sales and fees are computed first so that filter expressions can use them
+							p.Category = cat.name
+							p.estimateSales(crw.SalesCurves, cat.slug)
+							p.estimateFees(crw.FeeTable, cat.slug, crw.opts.cost)
+							p.Score = p.score(crw.opts.weights)
 							// If product is valid send it
-							if p.isValid(crw.opts) {
+							v := p.validate(crw.opts)
+							// Rejected products are recorded and near misses sent along with the reasons they were rejected
+							if !v.Valid {
+								p.Rejections = v.Rejections
+							}
+							crw.recordProduct(p, v)
+							crw.recordSnapshot(p, v)
+							if v.Valid {
+								crw.recordFound(p)
+								prods <- p
+							} else if crw.opts.nearMisses && v.nearMiss() {
 								prods <- p
 							}
 						}
@@ -232,10 +179,13 @@ func (crw *Crawler) Run(conn *websocket.Conn, prods chan Product) {
 	crw.conn = conn
 	// Reset Done channel to initial state so that calls to this channel block again
 	crw.Done = nil
+	// Start collecting parse statistics for this crawl
+	crw.resetReport()
+	crw.startRun()
 	// Get all the links that need to be scraped
 	links := crw.getLinks()
 	// Add all goroutines to the wait group
-	wg.Add(len(links))
+	crw.wg.Add(len(links))
 	// It is best not to use the default client which has no timeout
 	// This way no request takes more then the the specified timeout
 	// And the resources are not stuck
@@ -243,12 +193,14 @@ func (crw *Crawler) Run(conn *websocket.Conn, prods chan Product) {
 		Timeout: crw.Timeout * time.Second,
 	}
 	// Scrape every subcateogry in its own goroutine
-	for _, link := range links {
-		go crw.scrape(link, prods, httpClient)
+	for _, cl := range links {
+		go crw.scrape(cl.link, cl.cat, prods, httpClient)
 		sleep(minSleep, maxSleep)
 	}
 	// Wait for all goroutines to finish
-	wg.Wait()
+	crw.wg.Wait()
+	crw.finishReport()
+	crw.finishRun()
 	// We're done. Close the channel
 	close(prods)
 }
@@ -0,0 +1,204 @@
+package crawler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// criterion is a search criterion compiled to a filter expression
+// actual formats the value of a product which failed it and allowed describes the accepted values
+type criterion struct {
+	name    string
+	filter  *Filter
+	actual  func(p *Product) string
+	allowed string
+}
+
+// buildCriteria compiles the search options to filter expressions, one for every criterion in use
+// The bounds are widened with the tolerance of their criterion before being written in the expression
+// The user filter, or the default one, comes last so the rejections keep their usual order
+func buildCriteria(opts options, errs *ValidationError) []criterion {
+	var criteria []criterion
+	add := func(key, name, expr string, actual func(p *Product) string, allowed string) {
+		f, err := ParseFilter(expr)
+		if err != nil {
+			errs.add(key, "%s", err.Error())
+			return
+		}
+		criteria = append(criteria, criterion{name, f, actual, allowed})
+	}
+
+	// addRange adds the criterion of a field which must be inside optional bounds
+	addRange := func(name, field string, min, max bound, decimals int) {
+		if !min.set && !max.set {
+			return
+		}
+		tol := opts.toleranceFor(name)
+		min, max = min.widen(tol.lower), max.widen(tol.upper)
+		actual := func(p *Product) string {
+			return fmt.Sprintf("%.*f", decimals, filterFields[field].value(p).(float64))
+		}
+		add(name, name, rangeExpr(field, min, max), actual, rangeText(min, max, decimals))
+	}
+
+	addRange("price", "price", opts.minPrice, opts.maxPrice, 2)
+	addRange("bsr", "bsr", opts.minBSR, opts.maxBSR, 0)
+	addRange("reviews", "reviews", opts.minReviews, opts.maxReviews, 0)
+	addRange("rating", "rating", opts.minRating, opts.maxRating, 1)
+
+	// The size is a single criterion made of 3 optional bounds
+	if opts.maxLength.set || opts.maxWidth.set || opts.maxHeight.set {
+		tol := opts.toleranceFor("size")
+		var exprs, limits []string
+		for _, dim := range []struct {
+			field string
+			max   bound
+		}{{"length", opts.maxLength}, {"width", opts.maxWidth}, {"height", opts.maxHeight}} {
+			max := dim.max.widen(tol.upper)
+			if !max.set {
+				limits = append(limits, "any")
+				continue
+			}
+			exprs = append(exprs, rangeExpr(dim.field, bound{}, max))
+			limits = append(limits, fmt.Sprintf("%.2f", max.value))
+		}
+		actual := func(p *Product) string {
+			return fmt.Sprintf("%.2f x %.2f x %.2f", p.Length, p.Width, p.Height)
+		}
+		add("size", "size", strings.Join(exprs, " and "), actual, "at most "+strings.Join(limits, " x "))
+	}
+
+	addRange("weight", "weight", bound{}, opts.maxWeight, 2)
+
+	if opts.excludeAmazon {
+		add("exclude-amazon", "amazon", "fulfilled != '"+soldByAmazon+"'", func(p *Product) string { return p.Seller }, "not sold by Amazon")
+	}
+
+	if opts.primeOnly {
+		add("prime-only", "prime", "prime", func(p *Product) string { return "not eligible" }, "Prime eligible")
+	}
+
+	addRange("sellers", "sellers", bound{}, opts.maxSellers, 0)
+	addRange("variations", "variations", bound{}, opts.maxVariations, 0)
+	addRange("sales", "sales", opts.minSales, bound{}, 0)
+	addRange("revenue", "revenue", opts.minRevenue, bound{}, 2)
+	addRange("profit", "profit", opts.minProfit, bound{}, 2)
+	addRange("margin", "margin", opts.minMargin, bound{}, 2)
+	addRange("roi", "roi", opts.minROI, bound{}, 2)
+
+	brand := func(p *Product) string { return p.Brand }
+	// When an allow list is given the brand must be on it
+	if len(opts.includeBrands) > 0 {
+		if expr, err := anyOf("brand =", opts.includeBrands); err != nil {
+			errs.add("include-brands", "%s", err.Error())
+		} else {
+			add("include-brands", "brand", expr, brand, "one of "+strings.Join(opts.includeBrands, ", "))
+		}
+	}
+	if len(opts.excludeBrands) > 0 {
+		if expr, err := anyOf("brand =", opts.excludeBrands); err != nil {
+			errs.add("exclude-brands", "%s", err.Error())
+		} else {
+			add("exclude-brands", "brand", "not ("+expr+")", brand, "none of "+strings.Join(opts.excludeBrands, ", "))
+		}
+	}
+
+	// Drop products having any of the excluded keywords in their title
+	if len(opts.excludeKeywords) > 0 {
+		if expr, err := anyOf("title contains", opts.excludeKeywords); err != nil {
+			errs.add("exclude-keywords", "%s", err.Error())
+		} else {
+			actual := func(p *Product) string {
+				name := strings.ToLower(p.Name)
+				for _, kw := range opts.excludeKeywords {
+					if strings.Contains(name, strings.ToLower(kw)) {
+						return kw
+					}
+				}
+				return p.Name
+			}
+			add("exclude-keywords", "keywords", "not ("+expr+")", actual, "none of "+strings.Join(opts.excludeKeywords, ", "))
+		}
+	}
+
+	if opts.filter != nil {
+		criteria = append(criteria, criterion{"filter", opts.filter, func(p *Product) string { return "no match" }, opts.filter.String()})
+	}
+	return criteria
+}
+
+// rangeExpr writes the condition of a field inside optional bounds, at least one of them is set
+func rangeExpr(field string, min, max bound) string {
+	num := func(b bound) string { return strconv.FormatFloat(b.value, 'f', -1, 64) }
+	switch {
+	case min.set && max.set:
+		return fmt.Sprintf("%s between %s and %s", field, num(min), num(max))
+	case min.set:
+		return fmt.Sprintf("%s >= %s", field, num(min))
+	default:
+		return fmt.Sprintf("%s <= %s", field, num(max))
+	}
+}
+
+// anyOf writes the condition of a comparison being true for any of the texts, e.g. brand = 'a' or brand = 'b'
+func anyOf(comparison string, texts []string) (string, error) {
+	conds := make([]string, len(texts))
+	for i, text := range texts {
+		lit, err := quote(text)
+		if err != nil {
+			return "", err
+		}
+		conds[i] = comparison + " " + lit
+	}
+	return strings.Join(conds, " or "), nil
+}
+
+// quote writes a text literal of the filter language, which has no escapes for quotes
+func quote(text string) (string, error) {
+	switch {
+	case !strings.Contains(text, "'"):
+		return "'" + text + "'", nil
+	case !strings.Contains(text, `"`):
+		return `"` + text + `"`, nil
+	}
+	return "", fmt.Errorf("must not contain both ' and \" in %s", text)
+}
+
+// expression returns the filter expression of all the criteria in use, the one every product is checked against
+func (opts options) expression() string {
+	exprs := make([]string, len(opts.criteria))
+	for i, c := range opts.criteria {
+		exprs[i] = c.filter.String()
+		if len(opts.criteria) > 1 && hasOr(exprs[i]) {
+			exprs[i] = "(" + exprs[i] + ")"
+		}
+	}
+	return strings.Join(exprs, " and ")
+}
+
+// strictest returns the policy to apply when the fields of several criteria are missing
+// Rejecting wins over marking the criterion unknown which wins over accepting
+func (opts options) strictest(criteria []string) policy {
+	strictest := policyAccept
+	for _, name := range criteria {
+		switch p := opts.policies[name]; {
+		case p == policyReject:
+			return policyReject
+		case p != policyAccept:
+			strictest = policyUnknown
+		}
+	}
+	return strictest
+}
+
+// hasOr tells if an expression uses 'or', it then needs parentheses to be joined with 'and'
+func hasOr(expr string) bool {
+	toks, _ := lex(expr)
+	for _, tok := range toks {
+		if tok.typ == tokIdent && tok.text == "or" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,215 @@
+package crawler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// feeBracket is the fulfilment fee of the products weighing up to a limit (ounces)
+type feeBracket struct {
+	MaxWeight float64 `json:"maxWeight"`
+	Fee       float64 `json:"fee"`
+}
+
+// sizeTier describes the limits of an FBA size tier and its fulfilment fees
+// Dimensions are in inches and weights in ounces, a limit of 0 means no limit
+// MaxLengthGirth limits the longest side plus the girth (2 x (width + height))
+// DimDivisor turns the volume in cubic inches into a dimensional weight in pounds
+// Products heavier than the last bracket pay ExtraPerPound for every started pound above it
+type sizeTier struct {
+	Name           string       `json:"name"`
+	MaxLength      float64      `json:"maxLength"`
+	MaxWidth       float64      `json:"maxWidth"`
+	MaxHeight      float64      `json:"maxHeight"`
+	MaxLengthGirth float64      `json:"maxLengthGirth"`
+	MaxWeight      float64      `json:"maxWeight"`
+	DimDivisor     float64      `json:"dimDivisor"`
+	Fees           []feeBracket `json:"fees"`
+	ExtraPerPound  float64      `json:"extraPerPound"`
+}
+
+// FeeTable holds the FBA fees used to compute the product profit
+// Referral holds the referral fee percentage for every category slug (and "default")
+// Tiers are tried in order and the first one the product fits in is used
+type FeeTable struct {
+	Referral    map[string]float64 `json:"referral"`
+	MinReferral float64            `json:"minReferral"`
+	Tiers       []sizeTier         `json:"tiers"`
+}
+
+// defaultFeeTable holds the US marketplace fees used when no fee table is loaded
+var defaultFeeTable = FeeTable{
+	Referral: map[string]float64{
+		defaultCategory: 15,
+		"electronics":   8,
+		"pc":            8,
+		"photo":         8,
+		"videogames":    15,
+	},
+	MinReferral: 0.30,
+	Tiers: []sizeTier{
+		{Name: "small standard", MaxLength: 15, MaxWidth: 12, MaxHeight: 0.75, MaxWeight: 16,
+			Fees: []feeBracket{{10, 2.41}, {16, 2.48}}},
+		{Name: "large standard", MaxLength: 18, MaxWidth: 14, MaxHeight: 8, MaxWeight: 20 * ouncesPerPound, DimDivisor: 139,
+			Fees: []feeBracket{{10, 3.19}, {16, 3.28}, {32, 4.76}, {48, 5.26}}, ExtraPerPound: 0.38},
+		{Name: "small oversize", MaxLength: 60, MaxWidth: 30, MaxLengthGirth: 130, MaxWeight: 70 * ouncesPerPound, DimDivisor: 139,
+			Fees: []feeBracket{{32, 8.26}}, ExtraPerPound: 0.38},
+		{Name: "medium oversize", MaxLength: 108, MaxLengthGirth: 130, MaxWeight: 150 * ouncesPerPound, DimDivisor: 139,
+			Fees: []feeBracket{{32, 11.37}}, ExtraPerPound: 0.39},
+		{Name: "large oversize", MaxLength: 108, MaxLengthGirth: 165, MaxWeight: 150 * ouncesPerPound, DimDivisor: 139,
+			Fees: []feeBracket{{90 * ouncesPerPound, 75.78}}, ExtraPerPound: 0.79},
+		{Name: "special oversize", DimDivisor: 139,
+			Fees: []feeBracket{{90 * ouncesPerPound, 137.32}}, ExtraPerPound: 0.91},
+	},
+}
+
+// LoadFeeTable reads the fee table from a JSON file shaped like the FeeTable type
+// Every tier needs at least one fee bracket and the brackets are sorted by weight
+func LoadFeeTable(file string) (FeeTable, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return FeeTable{}, err
+	}
+	var table FeeTable
+	if err := json.Unmarshal(data, &table); err != nil {
+		return FeeTable{}, fmt.Errorf("invalid fee table file %s: %s", file, err)
+	}
+	if len(table.Tiers) == 0 {
+		return FeeTable{}, errors.New("fee table must have at least 1 size tier")
+	}
+	for _, tier := range table.Tiers {
+		if len(tier.Fees) == 0 {
+			return FeeTable{}, fmt.Errorf("size tier %s must have at least 1 fee bracket", tier.Name)
+		}
+		sort.Slice(tier.Fees, func(i, j int) bool { return tier.Fees[i].MaxWeight < tier.Fees[j].MaxWeight })
+	}
+	if _, ok := table.Referral[defaultCategory]; !ok {
+		if table.Referral == nil {
+			table.Referral = make(map[string]float64)
+		}
+		table.Referral[defaultCategory] = defaultFeeTable.Referral[defaultCategory]
+	}
+	return table, nil
+}
+
+// fits tells if a product with the sorted dimensions (longest first) and weight fits in the tier
+func (tier sizeTier) fits(dims [3]float64, weight float64) bool {
+	within := func(value, limit float64) bool {
+		return limit == 0 || value <= limit
+	}
+	girth := 2 * (dims[1] + dims[2])
+	return within(dims[0], tier.MaxLength) && within(dims[1], tier.MaxWidth) && within(dims[2], tier.MaxHeight) &&
+		within(dims[0]+girth, tier.MaxLengthGirth) && within(weight, tier.MaxWeight)
+}
+
+// fee returns the fulfilment fee of the tier for the billable weight (ounces)
+func (tier sizeTier) fee(weight float64) float64 {
+	for _, b := range tier.Fees {
+		if weight <= b.MaxWeight {
+			return b.Fee
+		}
+	}
+	last := tier.Fees[len(tier.Fees)-1]
+	pounds := math.Ceil((weight - last.MaxWeight) / ouncesPerPound)
+	return last.Fee + pounds*tier.ExtraPerPound
+}
+
+// cost is the user supplied cost of a product, either a landed cost per unit or a ratio of the price
+type cost struct {
+	value float64
+	ratio bool
+}
+
+// parseCost converts the user input to a cost
+// An empty value means the product costs nothing so the profit only accounts for the fees
+func parseCost(value, typ string) (cost, error) {
+	if value == "" {
+		return cost{}, nil
+	}
+	num, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(num) || math.IsInf(num, 0) {
+		return cost{}, errors.New("must be a number")
+	}
+	switch typ {
+	case "", "absolute":
+		if num < 0 {
+			return cost{}, errors.New("must not be negative")
+		}
+		return cost{value: num}, nil
+	case "percent":
+		if num < 0 || num > 100 {
+			return cost{}, errors.New("must be between 0 and 100 percent")
+		}
+		return cost{value: num, ratio: true}, nil
+	default:
+		return cost{}, fmt.Errorf("has an invalid type %s", typ)
+	}
+}
+
+// of returns the cost of a product sold at the price
+func (c cost) of(price float64) float64 {
+	if c.ratio {
+		return price * c.value / 100
+	}
+	return c.value
+}
+
+// estimateFees sets the size tier, the FBA fees, the profit, margin and ROI of the product
+// The referral fee needs the price and the fulfilment fee needs the size and weight
+// The profit is only computed when both fees are known
+func (prod *Product) estimateFees(table *FeeTable, cat string, c cost) {
+	if table == nil {
+		table = &defaultFeeTable
+	}
+	if prod.Status["price"] != statusFound {
+		return
+	}
+	percent, ok := table.Referral[cat]
+	if !ok {
+		percent = table.Referral[defaultCategory]
+	}
+	prod.ReferralFee = round2(math.Max(prod.Price*percent/100, table.MinReferral))
+
+	for _, field := range []string{"length", "width", "height", "weight"} {
+		if prod.Status[field] != statusFound {
+			return
+		}
+	}
+	dims := [3]float64{prod.Length, prod.Width, prod.Height}
+	sort.Sort(sort.Reverse(sort.Float64Slice(dims[:])))
+	for _, tier := range table.Tiers {
+		if !tier.fits(dims, prod.Weight) {
+			continue
+		}
+		// Bulky products are charged by their dimensional weight when it exceeds the real one
+		weight := prod.Weight
+		if tier.DimDivisor > 0 {
+			weight = math.Max(weight, dims[0]*dims[1]*dims[2]/tier.DimDivisor*ouncesPerPound)
+		}
+		prod.SizeTier = tier.Name
+		prod.FulfilmentFee = round2(tier.fee(weight))
+		break
+	}
+	if prod.SizeTier == "" {
+		return
+	}
+
+	prod.Cost = round2(c.of(prod.Price))
+	prod.Profit = round2(prod.Price - prod.ReferralFee - prod.FulfilmentFee - prod.Cost)
+	if prod.Price > 0 {
+		prod.Margin = round2(100 * prod.Profit / prod.Price)
+	}
+	if prod.Cost > 0 {
+		prod.ROI = round2(100 * prod.Profit / prod.Cost)
+	}
+}
+
+// round2 rounds money and percentages to 2 decimals
+func round2(f float64) float64 {
+	return math.Round(100*f) / 100
+}
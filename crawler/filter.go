@@ -0,0 +1,550 @@
+package crawler
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Filter is a compiled filter expression which is evaluated against every product
+// Expressions look like 'price between 10 and 30 and reviews < 200 and (weight_lb < 1 or prime)'
+type Filter struct {
+	src  string
+	root node
+	// fields are the product fields used by the expression
+	fields map[string]filterField
+}
+
+// kind is the type of the value an expression node evaluates to
+type kind int
+
+// These are the value types known to the filter language
+const (
+	kindNumber kind = iota
+	kindString
+	kindBool
+)
+
+// String returns the name of the type to be used in error messages
+func (k kind) String() string {
+	switch k {
+	case kindNumber:
+		return "number"
+	case kindString:
+		return "text"
+	default:
+		return "boolean"
+	}
+}
+
+// filterField describes a product field that can be used in filter expressions
+// needs are the extracted fields the value comes from, when one of them was not found the value is unknown
+// and the missing field policy of the criterion decides what happens with the product
+type filterField struct {
+	kind      kind
+	value     func(p *Product) interface{}
+	criterion string
+	needs     []string
+}
+
+// feeFields are the extracted fields the fees and the profit are computed from
+var feeFields = []string{"price", "length", "width", "height", "weight"}
+
+// filterFields holds all product fields known to the filter language
+var filterFields = map[string]filterField{
+	"price":      {kindNumber, func(p *Product) interface{} { return p.Price }, "price", []string{"price"}},
+	"bsr":        {kindNumber, func(p *Product) interface{} { return float64(p.BSR) }, "bsr", []string{"bsr"}},
+	"reviews":    {kindNumber, func(p *Product) interface{} { return float64(p.Reviews) }, "reviews", []string{"reviews"}},
+	"rating":     {kindNumber, func(p *Product) interface{} { return p.Rating }, "rating", []string{"rating"}},
+	"length":     {kindNumber, func(p *Product) interface{} { return p.Length }, "size", []string{"length"}},
+	"width":      {kindNumber, func(p *Product) interface{} { return p.Width }, "size", []string{"width"}},
+	"height":     {kindNumber, func(p *Product) interface{} { return p.Height }, "size", []string{"height"}},
+	"weight":     {kindNumber, func(p *Product) interface{} { return p.Weight }, "weight", []string{"weight"}},
+	"weight_oz":  {kindNumber, func(p *Product) interface{} { return p.Weight }, "weight", []string{"weight"}},
+	"weight_lb":  {kindNumber, func(p *Product) interface{} { return p.Weight / ouncesPerPound }, "weight", []string{"weight"}},
+	"sellers":    {kindNumber, func(p *Product) interface{} { return float64(p.Offers) }, "sellers", []string{"offers"}},
+	"offers":     {kindNumber, func(p *Product) interface{} { return float64(p.Offers) }, "sellers", []string{"offers"}},
+	"images":     {kindNumber, func(p *Product) interface{} { return float64(p.Images) }, "", nil},
+	"variations": {kindNumber, func(p *Product) interface{} { return float64(p.Variations) }, "", nil},
+	"score":      {kindNumber, func(p *Product) interface{} { return p.Score }, "", nil},
+	"sales":      {kindNumber, func(p *Product) interface{} { return float64(p.Sales) }, "sales", []string{"bsr"}},
+	"revenue":    {kindNumber, func(p *Product) interface{} { return p.Revenue }, "revenue", []string{"bsr", "price"}},
+	"fees":       {kindNumber, func(p *Product) interface{} { return p.ReferralFee + p.FulfilmentFee }, "profit", feeFields},
+	"cost":       {kindNumber, func(p *Product) interface{} { return p.Cost }, "", nil},
+	"profit":     {kindNumber, func(p *Product) interface{} { return p.Profit }, "profit", feeFields},
+	"margin":     {kindNumber, func(p *Product) interface{} { return p.Margin }, "margin", feeFields},
+	"roi":        {kindNumber, func(p *Product) interface{} { return p.ROI }, "roi", feeFields},
+	"prime":      {kindBool, func(p *Product) interface{} { return p.Prime }, "", nil},
+	"asin":       {kindString, func(p *Product) interface{} { return p.ASIN }, "", nil},
+	"parent":     {kindString, func(p *Product) interface{} { return p.Parent }, "", nil},
+	"name":       {kindString, func(p *Product) interface{} { return p.Name }, "", nil},
+	"title":      {kindString, func(p *Product) interface{} { return p.Name }, "", nil},
+	"brand":      {kindString, func(p *Product) interface{} { return p.Brand }, "", nil},
+	"seller":     {kindString, func(p *Product) interface{} { return p.Seller }, "", nil},
+	"fulfilled":  {kindString, func(p *Product) interface{} { return p.Fulfilled }, "", nil},
+	"category":   {kindString, func(p *Product) interface{} { return p.Category }, "", nil},
+	"tier":       {kindString, func(p *Product) interface{} { return p.SizeTier }, "size", []string{"length", "width", "height", "weight"}},
+}
+
+// unknown is the value of a field which was not found on the product page and of the conditions depending on it
+// Conditions follow three valued logic, e.g. 'unknown or true' is true while 'unknown and true' stays unknown
+type unknown struct{}
+
+// isUnknown tells if an evaluated value is unknown
+func isUnknown(v interface{}) bool {
+	_, ok := v.(unknown)
+	return ok
+}
+
+// ParseFilter compiles a filter expression
+// The error tells the position in the expression where parsing failed
+func ParseFilter(src string) (*Filter, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	ps := &parser{toks: toks, fields: make(map[string]filterField)}
+	root, err := ps.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := ps.peek(); tok.typ != tokEOF {
+		return nil, fmt.Errorf("Unexpected %q at position %d", tok.text, tok.pos)
+	}
+	if root.kind() != kindBool {
+		return nil, fmt.Errorf("Filter must be a condition, not a %s", root.kind())
+	}
+	return &Filter{src: src, root: root, fields: ps.fields}, nil
+}
+
+// Match tells if the product satisfies the filter
+// A product whose missing fields leave the result unknown does not match
+func (f *Filter) Match(p *Product) bool {
+	match, known := f.test(p)
+	return match && known
+}
+
+// test evaluates the filter, known is false when the fields which were not found leave the result open
+func (f *Filter) test(p *Product) (match bool, known bool) {
+	v := f.root.eval(p)
+	if isUnknown(v) {
+		return false, false
+	}
+	return v.(bool), true
+}
+
+// missing returns the criteria of the fields used by the filter which were not found on the product
+// Their missing field policies decide about a product the filter cannot tell about
+func (f *Filter) missing(p *Product) []string {
+	var criteria []string
+	seen := make(map[string]bool)
+	for _, field := range f.fields {
+		for _, name := range field.needs {
+			if !p.Found(name) && !seen[field.criterion] {
+				seen[field.criterion] = true
+				criteria = append(criteria, field.criterion)
+			}
+		}
+	}
+	sort.Strings(criteria)
+	return criteria
+}
+
+// String returns the source of the filter expression
+func (f *Filter) String() string {
+	return f.src
+}
+
+// tokenType tells what kind of token the lexer found
+type tokenType int
+
+// These are the tokens of the filter language
+const (
+	tokEOF tokenType = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+// token is a single lexical unit of a filter expression
+type token struct {
+	typ  tokenType
+	text string
+	pos  int
+}
+
+// lex splits the filter expression into tokens
+func lex(src string) ([]token, error) {
+	var toks []token
+	rs := []rune(src)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			toks = append(toks, token{tokLParen, "(", i})
+			i++
+		case r == ')':
+			toks = append(toks, token{tokRParen, ")", i})
+			i++
+		case unicode.IsDigit(r) || r == '.':
+			start := i
+			for i < len(rs) && (unicode.IsDigit(rs[i]) || rs[i] == '.') {
+				i++
+			}
+			toks = append(toks, token{tokNumber, string(rs[start:i]), start})
+		case r == '"' || r == '\'':
+			start := i
+			i++
+			for i < len(rs) && rs[i] != r {
+				i++
+			}
+			if i == len(rs) {
+				return nil, fmt.Errorf("Unterminated text at position %d", start)
+			}
+			toks = append(toks, token{tokString, string(rs[start+1 : i]), start})
+			i++
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(rs) && (unicode.IsLetter(rs[i]) || unicode.IsDigit(rs[i]) || rs[i] == '_') {
+				i++
+			}
+			toks = append(toks, token{tokIdent, strings.ToLower(string(rs[start:i])), start})
+		case strings.ContainsRune("<>=!", r):
+			start := i
+			i++
+			if i < len(rs) && rs[i] == '=' {
+				i++
+			}
+			op := string(rs[start:i])
+			if op == "!" {
+				return nil, fmt.Errorf("Unexpected %q at position %d", op, start)
+			}
+			toks = append(toks, token{tokOp, op, start})
+		default:
+			return nil, fmt.Errorf("Unexpected %q at position %d", r, i)
+		}
+	}
+	toks = append(toks, token{tokEOF, "end of filter", len(rs)})
+	return toks, nil
+}
+
+// parser builds the expression tree from the tokens using recursive descent
+type parser struct {
+	toks   []token
+	pos    int
+	fields map[string]filterField
+}
+
+// peek returns the current token without consuming it
+func (ps *parser) peek() token {
+	return ps.toks[ps.pos]
+}
+
+// next consumes and returns the current token
+func (ps *parser) next() token {
+	tok := ps.toks[ps.pos]
+	if tok.typ != tokEOF {
+		ps.pos++
+	}
+	return tok
+}
+
+// keyword consumes the current token if it is the given keyword
+func (ps *parser) keyword(kw string) bool {
+	if tok := ps.peek(); tok.typ == tokIdent && tok.text == kw {
+		ps.pos++
+		return true
+	}
+	return false
+}
+
+// parseOr parses 'a or b or c'
+func (ps *parser) parseOr() (node, error) {
+	left, err := ps.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := ps.peek()
+		if !ps.keyword("or") {
+			return left, nil
+		}
+		right, err := ps.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if left.kind() != kindBool || right.kind() != kindBool {
+			return nil, fmt.Errorf("Both sides of 'or' must be conditions at position %d", tok.pos)
+		}
+		left = &logicNode{or: true, left: left, right: right}
+	}
+}
+
+// parseAnd parses 'a and b and c'
+func (ps *parser) parseAnd() (node, error) {
+	left, err := ps.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := ps.peek()
+		if !ps.keyword("and") {
+			return left, nil
+		}
+		right, err := ps.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		if left.kind() != kindBool || right.kind() != kindBool {
+			return nil, fmt.Errorf("Both sides of 'and' must be conditions at position %d", tok.pos)
+		}
+		left = &logicNode{or: false, left: left, right: right}
+	}
+}
+
+// parseNot parses 'not a'
+func (ps *parser) parseNot() (node, error) {
+	tok := ps.peek()
+	if !ps.keyword("not") {
+		return ps.parseComparison()
+	}
+	x, err := ps.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	if x.kind() != kindBool {
+		return nil, fmt.Errorf("'not' must be followed by a condition at position %d", tok.pos)
+	}
+	return &notNode{x}, nil
+}
+
+// parseComparison parses 'a < b', 'a between b and c' and 'a contains b'
+func (ps *parser) parseComparison() (node, error) {
+	left, err := ps.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	tok := ps.peek()
+	switch {
+	case tok.typ == tokOp:
+		ps.next()
+		right, err := ps.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		if left.kind() != right.kind() {
+			return nil, fmt.Errorf("Cannot compare %s with %s at position %d", left.kind(), right.kind(), tok.pos)
+		}
+		if left.kind() != kindNumber && tok.text != "=" && tok.text != "==" && tok.text != "!=" {
+			return nil, fmt.Errorf("Operator %s needs numbers at position %d", tok.text, tok.pos)
+		}
+		return &compareNode{op: tok.text, left: left, right: right}, nil
+	case ps.keyword("between"):
+		low, err := ps.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		if !ps.keyword("and") {
+			return nil, fmt.Errorf("Expected 'and' at position %d", ps.peek().pos)
+		}
+		high, err := ps.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		if left.kind() != kindNumber || low.kind() != kindNumber || high.kind() != kindNumber {
+			return nil, fmt.Errorf("'between' needs numbers at position %d", tok.pos)
+		}
+		return &betweenNode{x: left, low: low, high: high}, nil
+	case ps.keyword("contains"):
+		right, err := ps.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		if left.kind() != kindString || right.kind() != kindString {
+			return nil, fmt.Errorf("'contains' needs text at position %d", tok.pos)
+		}
+		return &containsNode{left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+// parsePrimary parses literals, product fields and parenthesized expressions
+func (ps *parser) parsePrimary() (node, error) {
+	tok := ps.next()
+	switch tok.typ {
+	case tokNumber:
+		num, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid number %q at position %d", tok.text, tok.pos)
+		}
+		return &literalNode{value: num, typ: kindNumber}, nil
+	case tokString:
+		return &literalNode{value: tok.text, typ: kindString}, nil
+	case tokIdent:
+		switch tok.text {
+		case "true", "false":
+			return &literalNode{value: tok.text == "true", typ: kindBool}, nil
+		}
+		field, ok := filterFields[tok.text]
+		if !ok {
+			return nil, fmt.Errorf("Unknown field %q at position %d", tok.text, tok.pos)
+		}
+		ps.fields[tok.text] = field
+		return &fieldNode{field}, nil
+	case tokLParen:
+		x, err := ps.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := ps.next(); closing.typ != tokRParen {
+			return nil, fmt.Errorf("Expected ')' at position %d", closing.pos)
+		}
+		return x, nil
+	}
+	return nil, fmt.Errorf("Unexpected %q at position %d", tok.text, tok.pos)
+}
+
+// node is a part of the expression tree
+// The types are checked while parsing so evaluation never fails, any value may be unknown though
+type node interface {
+	kind() kind
+	eval(p *Product) interface{}
+}
+
+// literalNode is a number, text or boolean written in the expression
+type literalNode struct {
+	value interface{}
+	typ   kind
+}
+
+func (n *literalNode) kind() kind                  { return n.typ }
+func (n *literalNode) eval(p *Product) interface{} { return n.value }
+
+// fieldNode reads a product field
+type fieldNode struct {
+	field filterField
+}
+
+func (n *fieldNode) kind() kind { return n.field.kind }
+func (n *fieldNode) eval(p *Product) interface{} {
+	for _, name := range n.field.needs {
+		if !p.Found(name) {
+			return unknown{}
+		}
+	}
+	return n.field.value(p)
+}
+
+// logicNode combines two conditions with 'and' or 'or'
+type logicNode struct {
+	or          bool
+	left, right node
+}
+
+func (n *logicNode) kind() kind { return kindBool }
+func (n *logicNode) eval(p *Product) interface{} {
+	// A side deciding the result on its own wins over an unknown one
+	decisive := n.or
+	l, r := n.left.eval(p), n.right.eval(p)
+	if l == decisive || r == decisive {
+		return decisive
+	}
+	if isUnknown(l) || isUnknown(r) {
+		return unknown{}
+	}
+	return !decisive
+}
+
+// notNode negates a condition
+type notNode struct {
+	x node
+}
+
+func (n *notNode) kind() kind { return kindBool }
+func (n *notNode) eval(p *Product) interface{} {
+	x := n.x.eval(p)
+	if isUnknown(x) {
+		return x
+	}
+	return !x.(bool)
+}
+
+// compareNode compares two values of the same type
+// Text is compared ignoring the case
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+func (n *compareNode) kind() kind { return kindBool }
+func (n *compareNode) eval(p *Product) interface{} {
+	l, r := n.left.eval(p), n.right.eval(p)
+	if isUnknown(l) || isUnknown(r) {
+		return unknown{}
+	}
+	switch lv := l.(type) {
+	case float64:
+		rv := r.(float64)
+		switch n.op {
+		case "<":
+			return lv < rv
+		case "<=":
+			return lv <= rv
+		case ">":
+			return lv > rv
+		case ">=":
+			return lv >= rv
+		case "!=":
+			return lv != rv
+		default:
+			return lv == rv
+		}
+	case string:
+		equal := strings.EqualFold(lv, r.(string))
+		if n.op == "!=" {
+			return !equal
+		}
+		return equal
+	default:
+		equal := l.(bool) == r.(bool)
+		if n.op == "!=" {
+			return !equal
+		}
+		return equal
+	}
+}
+
+// betweenNode checks that a number is inside a range, bounds included
+type betweenNode struct {
+	x, low, high node
+}
+
+func (n *betweenNode) kind() kind { return kindBool }
+func (n *betweenNode) eval(p *Product) interface{} {
+	x, low, high := n.x.eval(p), n.low.eval(p), n.high.eval(p)
+	if isUnknown(x) || isUnknown(low) || isUnknown(high) {
+		return unknown{}
+	}
+	return x.(float64) >= low.(float64) && x.(float64) <= high.(float64)
+}
+
+// containsNode checks that a text contains another text ignoring the case
+type containsNode struct {
+	left, right node
+}
+
+func (n *containsNode) kind() kind { return kindBool }
+func (n *containsNode) eval(p *Product) interface{} {
+	l, r := n.left.eval(p), n.right.eval(p)
+	if isUnknown(l) || isUnknown(r) {
+		return unknown{}
+	}
+	return strings.Contains(strings.ToLower(l.(string)), strings.ToLower(r.(string)))
+}
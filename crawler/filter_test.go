@@ -0,0 +1,271 @@
+package crawler
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// testProduct returns a product whose fields were all found except the missing ones
+func testProduct(missing ...string) *Product {
+	p := &Product{
+		ASIN:    "B01ABCDEFG",
+		Name:    "Red Garden Hose",
+		Brand:   "Acme",
+		Price:   20,
+		BSR:     1500,
+		Reviews: 120,
+		Rating:  4.5,
+		Length:  10,
+		Width:   5,
+		Height:  2,
+		Weight:  32,
+		Offers:  3,
+		Prime:   true,
+		Profit:  6,
+		Status:  make(map[string]fieldStatus),
+	}
+	for _, field := range []string{"asin", "name", "brand", "price", "bsr", "reviews", "rating", "length", "width", "height", "weight", "offers"} {
+		p.Status[field] = statusFound
+	}
+	for _, field := range missing {
+		p.Status[field] = statusMissing
+	}
+	return p
+}
+
+func TestFilterPrecedence(t *testing.T) {
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		// 'and' binds tighter than 'or'
+		{"price > 100 and reviews > 100 or prime", true},
+		{"price > 100 and (reviews > 100 or prime)", false},
+		{"prime or price > 100 and reviews > 500", true},
+		{"(prime or price > 100) and reviews > 500", false},
+		// 'not' binds tighter than 'and' and 'or'
+		{"not prime and price < 100", false},
+		{"not (prime and price > 100)", true},
+		{"not not prime", true},
+		{"not price > 100 or false", true},
+		// Comparisons bind tighter than the logic operators
+		{"price between 10 and 30 and reviews < 200", true},
+		{"price between 25 and 30 or rating >= 4.5", true},
+	}
+	for _, tt := range tests {
+		f, err := ParseFilter(tt.expr)
+		if err != nil {
+			t.Errorf("ParseFilter(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		if got := f.Match(testProduct()); got != tt.want {
+			t.Errorf("%q matched %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestFilterComparisons(t *testing.T) {
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"price < 20", false},
+		{"price <= 20", true},
+		{"price > 19.99", true},
+		{"price >= 20.01", false},
+		{"price = 20", true},
+		{"price == 20", true},
+		{"price != 20", false},
+		{"price between 20 and 30", true},
+		{"price between 10 and 20", true},
+		{"price between 20.5 and 30", false},
+		{"weight_lb = 2", true},
+		{"weight_oz = 32", true},
+		{"brand = 'acme'", true},
+		{`brand != "ACME"`, false},
+		{"title contains 'garden'", true},
+		{"name contains 'GARDEN HOSE'", true},
+		{"title contains 'pool'", false},
+		{"prime = true", true},
+		{"prime != false", true},
+		{"PRICE < 30 AND Prime", true},
+	}
+	for _, tt := range tests {
+		f, err := ParseFilter(tt.expr)
+		if err != nil {
+			t.Errorf("ParseFilter(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		if got := f.Match(testProduct()); got != tt.want {
+			t.Errorf("%q matched %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestFilterErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{"colour = 'red'", `Unknown field "colour" at position 0`},
+		{"price < 10 and stars > 4", `Unknown field "stars" at position 15`},
+		{"price", "Filter must be a condition, not a number"},
+		{"brand", "Filter must be a condition, not a text"},
+		{"price < 'ten'", "Cannot compare number with text at position 6"},
+		{"brand < 'acme'", "Operator < needs numbers at position 6"},
+		{"prime > true", "Operator > needs numbers at position 6"},
+		{"brand contains 5", "'contains' needs text at position 6"},
+		{"brand between 1 and 2", "'between' needs numbers at position 6"},
+		{"price between 1 2", "Expected 'and' at position 16"},
+		{"price and prime", "Both sides of 'and' must be conditions at position 6"},
+		{"prime or price", "Both sides of 'or' must be conditions at position 6"},
+		{"not price", "'not' must be followed by a condition at position 0"},
+		{"(price < 10", "Expected ')' at position 11"},
+		{"price < 10)", `Unexpected ")" at position 10`},
+		{"price < ", `Unexpected "end of filter" at position 8`},
+		{"brand = 'acme", "Unterminated text at position 8"},
+		{"price ! 10", `Unexpected "!" at position 6`},
+		{"price < 1.2.3", `Invalid number "1.2.3" at position 8`},
+		{"price # 10", `Unexpected '#' at position 6`},
+		{"", `Unexpected "end of filter" at position 0`},
+	}
+	for _, tt := range tests {
+		_, err := ParseFilter(tt.expr)
+		if err == nil {
+			t.Errorf("ParseFilter(%q) succeeded, want error %q", tt.expr, tt.err)
+			continue
+		}
+		if err.Error() != tt.err {
+			t.Errorf("ParseFilter(%q) failed with %q, want %q", tt.expr, err, tt.err)
+		}
+	}
+}
+
+func TestFilterUnknownFields(t *testing.T) {
+	tests := []struct {
+		expr    string
+		missing []string
+		match   bool
+		known   bool
+		want    []string
+	}{
+		{"price < 30", nil, true, true, nil},
+		{"price < 30", []string{"price"}, false, false, []string{"price"}},
+		// A side deciding the result on its own wins over an unknown one
+		{"price < 30 or prime", []string{"price"}, true, true, []string{"price"}},
+		{"price < 30 and prime", []string{"price"}, false, false, []string{"price"}},
+		{"price < 10 and reviews > 100", []string{"reviews"}, false, true, []string{"reviews"}},
+		{"price < 10 or reviews > 100", []string{"reviews"}, false, false, []string{"reviews"}},
+		{"not price < 30", []string{"price"}, false, false, []string{"price"}},
+		// Derived fields are unknown when the fields they come from were not found
+		{"profit > 5", []string{"weight"}, false, false, []string{"profit"}},
+		{"sales > 0 and revenue > 0", []string{"bsr"}, false, false, []string{"revenue", "sales"}},
+		{"length < 20 and width < 20", []string{"length", "width"}, false, false, []string{"size"}},
+		// Fields not extracted from the page are never unknown
+		{"images >= 0", []string{"price"}, true, true, nil},
+	}
+	for _, tt := range tests {
+		f, err := ParseFilter(tt.expr)
+		if err != nil {
+			t.Errorf("ParseFilter(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		p := testProduct(tt.missing...)
+		match, known := f.test(p)
+		if match != tt.match || known != tt.known {
+			t.Errorf("%q without %v tested (%v, %v), want (%v, %v)", tt.expr, tt.missing, match, known, tt.match, tt.known)
+		}
+		if got := f.missing(p); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q without %v is missing %v, want %v", tt.expr, tt.missing, got, tt.want)
+		}
+	}
+}
+
+func TestFilterMissingFieldPolicies(t *testing.T) {
+	tests := []struct {
+		name       string
+		values     url.Values
+		missing    []string
+		valid      bool
+		rejections []string
+		unknown    []string
+	}{
+		{
+			name:    "unknown by default",
+			values:  url.Values{"filter": {"price < 30"}},
+			missing: []string{"price"},
+			valid:   true,
+			unknown: []string{"filter"},
+		},
+		{
+			name:       "reject",
+			values:     url.Values{"filter": {"price < 30"}, "policy-price": {"reject"}},
+			missing:    []string{"price"},
+			rejections: []string{"filter is not found (allowed price < 30)"},
+		},
+		{
+			name:    "accept",
+			values:  url.Values{"filter": {"price < 30"}, "policy-price": {"accept"}},
+			missing: []string{"price"},
+			valid:   true,
+		},
+		{
+			name:       "strictest of several criteria",
+			values:     url.Values{"filter": {"price < 30 and reviews > 10"}, "policy-price": {"accept"}, "policy-reviews": {"reject"}},
+			missing:    []string{"price", "reviews"},
+			rejections: []string{"filter is not found (allowed price < 30 and reviews > 10)"},
+		},
+		{
+			name:    "known result needs no policy",
+			values:  url.Values{"filter": {"price < 30 or prime"}, "policy-price": {"reject"}},
+			missing: []string{"price"},
+			valid:   true,
+		},
+		{
+			name:       "bounds keep their own criterion",
+			values:     url.Values{"max-price": {"30"}, "min-reviews": {"10"}, "policy-price": {"reject"}},
+			missing:    []string{"price", "reviews"},
+			rejections: []string{"price is not found (allowed at most 30.00)"},
+			unknown:    []string{"reviews"},
+		},
+	}
+	for _, tt := range tests {
+		tt.values.Set("categories", "1")
+		opts, err := parseOptions(tt.values, nil)
+		if err != nil {
+			t.Errorf("%s: parseOptions failed: %v", tt.name, err)
+			continue
+		}
+		p := testProduct(tt.missing...)
+		v := p.validate(opts)
+		var rejections []string
+		for _, rej := range v.Rejections {
+			rejections = append(rejections, rej.String())
+		}
+		if v.Valid != tt.valid || !reflect.DeepEqual(rejections, tt.rejections) {
+			t.Errorf("%s: got valid %v with rejections %v, want %v with %v", tt.name, v.Valid, rejections, tt.valid, tt.rejections)
+		}
+		if !reflect.DeepEqual(p.Unknown, tt.unknown) {
+			t.Errorf("%s: got unknown criteria %v, want %v", tt.name, p.Unknown, tt.unknown)
+		}
+	}
+}
+
+func TestCriteriaExpression(t *testing.T) {
+	values := url.Values{
+		"categories":     {"1"},
+		"min-price":      {"10"},
+		"max-price":      {"30"},
+		"include-brands": {"Acme, Bob's"},
+		"filter":         {"prime or reviews > 10"},
+	}
+	opts, err := parseOptions(values, nil)
+	if err != nil {
+		t.Fatalf("parseOptions failed: %v", err)
+	}
+	want := `price between 10 and 30 and (brand = 'Acme' or brand = "Bob's") and (prime or reviews > 10)`
+	if got := opts.expression(); got != want {
+		t.Errorf("got expression %q, want %q", got, want)
+	}
+}
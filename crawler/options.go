@@ -0,0 +1,322 @@
+package crawler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// bound is an optional limit of a criterion
+// An unset bound means the criterion is unbounded on that side
+type bound struct {
+	value float64
+	set   bool
+}
+
+// widen applies the tolerance to a set bound
+func (b bound) widen(f func(float64) float64) bound {
+	if b.set {
+		b.value = f(b.value)
+	}
+	return b
+}
+
+// options holds parameters necessary to filter products
+type options struct {
+	categories []category
+	minPrice   bound
+	maxPrice   bound
+	minBSR     bound
+	maxBSR     bound
+	minReviews bound
+	maxReviews bound
+	minRating  bound
+	maxRating  bound
+	maxLength  bound
+	maxWidth   bound
+	maxHeight  bound
+	maxWeight  bound
+	maxSellers bound
+	tolerance  float64
+	// maxVariations is the max number of child ASINs of a variation listing
+	maxVariations bound
+	// tolerances holds the criteria with their own tolerance instead of the global one
+	tolerances map[string]tolerance
+	// Lists of brands to allow or deny and keywords to deny in product titles
+	includeBrands   []string
+	excludeBrands   []string
+	excludeKeywords []string
+	// policies tells for every criterion what to do when the product fields are missing
+	policies map[string]policy
+	// Flags to drop products sold by Amazon itself or not eligible for Prime
+	excludeAmazon bool
+	primeOnly     bool
+	// nearMisses also sends the products which failed only a few criteria
+	nearMisses bool
+	// filter is an optional expression every product must satisfy
+	filter *Filter
+	// criteria are the criteria in use compiled to filter expressions, the user filter included
+	criteria []criterion
+	// weights tells how much every factor counts in the product score
+	weights weights
+	// Lower limits of the estimated monthly units sold and revenue
+	minSales   bound
+	minRevenue bound
+	// cost is the landed cost of a unit used to compute the profit and the lower limits on it
+	cost      cost
+	minProfit bound
+	minMargin bound
+	minROI    bound
+}
+
+// MapOptions extracts the request data and maps the input to Crawler options
+// This way the crawler knows which options to use when filtering products
+// The request is either a submitted form or a JSON body using the form field names as keys
+// Invalid input results in a ValidationError listing every invalid field
+func (crw *Crawler) MapOptions(r *http.Request) error {
+	values, err := RequestValues(r)
+	if err != nil {
+		return err
+	}
+	return crw.SetOptions(values)
+}
+
+// RequestValues reads the search input of a request, either a submitted form or a JSON body
+func RequestValues(r *http.Request) (url.Values, error) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		values, err := jsonValues(r.Body)
+		if err != nil {
+			return nil, ValidationError{{Field: "body", Message: err.Error()}}
+		}
+		return values, nil
+	}
+	if err := r.ParseForm(); err != nil {
+		return nil, ValidationError{{Field: "body", Message: err.Error()}}
+	}
+	return r.Form, nil
+}
+
+// SetOptions maps the search input to Crawler options without a request
+// This way crawls can be started headlessly, e.g. by a schedule, from the values saved with them
+func (crw *Crawler) SetOptions(values url.Values) error {
+	opts, err := parseOptions(values, crw.DefaultFilter)
+	if err != nil {
+		return err
+	}
+	// We save these options on the crawler along with the input to record it with the run
+	crw.opts = opts
+	crw.values = values
+
+	return nil
+}
+
+// parseOptions converts the search input to options
+// Every bound is optional so a missing or empty value leaves the criterion unbounded
+// All the fields are checked so the returned ValidationError lists every invalid one
+func parseOptions(values url.Values, defaultFilter *Filter) (options, error) {
+	var opts options
+	var errs ValidationError
+
+	bounds := []struct {
+		key string
+		b   *bound
+	}{
+		{"min-price", &opts.minPrice},
+		{"max-price", &opts.maxPrice},
+		{"min-bsr", &opts.minBSR},
+		{"max-bsr", &opts.maxBSR},
+		{"min-reviews", &opts.minReviews},
+		{"max-reviews", &opts.maxReviews},
+		{"min-rating", &opts.minRating},
+		{"max-rating", &opts.maxRating},
+		{"max-length", &opts.maxLength},
+		{"max-width", &opts.maxWidth},
+		{"max-height", &opts.maxHeight},
+		{"max-weight", &opts.maxWeight},
+		{"max-sellers", &opts.maxSellers},
+		{"max-variations", &opts.maxVariations},
+		{"min-sales", &opts.minSales},
+		{"min-revenue", &opts.minRevenue},
+		{"min-profit", &opts.minProfit},
+		{"min-margin", &opts.minMargin},
+		{"min-roi", &opts.minROI},
+	}
+	for _, in := range bounds {
+		b, err := parseBound(values.Get(in.key))
+		if err != nil {
+			errs.add(in.key, "must be a number")
+			continue
+		}
+		*in.b = b
+	}
+
+	c, err := parseCost(strings.TrimSpace(values.Get("cost")), values.Get("cost-type"))
+	if err != nil {
+		errs.add("cost", "%s", err.Error())
+	}
+	opts.cost = c
+
+	// The global tolerance is optional as well and defaults to no tolerance at all
+	if tol := strings.TrimSpace(values.Get("tolerance")); tol != "" {
+		num, err := strconv.ParseFloat(tol, 64)
+		if err != nil || math.IsNaN(num) || math.IsInf(num, 0) {
+			errs.add("tolerance", "must be a number")
+		}
+		opts.tolerance = num
+	}
+
+	// Every criterion may have its own tolerance, otherwise the global one is used
+	opts.tolerances = make(map[string]tolerance)
+	for _, criterion := range toleranceCriteria {
+		key := "tolerance-" + criterion
+		t, err := parseTolerance(strings.TrimSpace(values.Get(key)), values.Get(key+"-type"), opts.tolerance)
+		if err != nil {
+			errs.add(key, "%s", err.Error())
+			continue
+		}
+		opts.tolerances[criterion] = t
+	}
+
+	opts.policies = make(map[string]policy)
+	criteria := make([]string, 0, len(criterionFields))
+	for criterion := range criterionFields {
+		criteria = append(criteria, criterion)
+	}
+	// Sort the criteria so errors always come in the same order
+	sort.Strings(criteria)
+	for _, criterion := range criteria {
+		key := "policy-" + criterion
+		p, err := parsePolicy(values.Get(key))
+		if err != nil {
+			errs.add(key, "%s", err.Error())
+			continue
+		}
+		opts.policies[criterion] = p
+	}
+
+	opts.filter = defaultFilter
+	if expr := strings.TrimSpace(values.Get("filter")); expr != "" {
+		f, err := ParseFilter(expr)
+		if err != nil {
+			errs.add("filter", "%s", err.Error())
+		}
+		opts.filter = f
+	}
+
+	cats, err := filterCategories(values["categories"])
+	if err != nil {
+		errs.add("categories", "%s", err.Error())
+	} else if len(cats) == 0 {
+		errs.add("categories", "must contain at least one category")
+	}
+	opts.categories = cats
+
+	// Score weights are optional and every missing one keeps its default
+	opts.weights = defaultWeights
+	scoreWeights := []struct {
+		key string
+		w   *float64
+	}{
+		{"score-bsr", &opts.weights.bsr},
+		{"score-reviews", &opts.weights.reviews},
+		{"score-price", &opts.weights.price},
+		{"score-size", &opts.weights.size},
+		{"score-rating", &opts.weights.rating},
+		{"score-competition", &opts.weights.competition},
+	}
+	for _, in := range scoreWeights {
+		b, err := parseBound(values.Get(in.key))
+		if err != nil {
+			errs.add(in.key, "must be a number")
+			continue
+		}
+		if b.set {
+			*in.w = b.value
+		}
+	}
+
+	opts.includeBrands = splitList(values.Get("include-brands"))
+	opts.excludeBrands = splitList(values.Get("exclude-brands"))
+	opts.excludeKeywords = splitList(values.Get("exclude-keywords"))
+	// Unchecked checkboxes are not sent at all with the form
+	opts.excludeAmazon = values.Get("exclude-amazon") != ""
+	opts.primeOnly = values.Get("prime-only") != ""
+	opts.nearMisses = values.Get("near-misses") != ""
+
+	// Values which were parsed must also make sense
+	validateOptions(opts, &errs)
+	opts.criteria = buildCriteria(opts, &errs)
+	if len(errs) > 0 {
+		return opts, errs
+	}
+
+	return opts, nil
+}
+
+// parseBound converts the user input to a bound
+// An empty input results in an unset bound
+func parseBound(input string) (bound, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return bound{}, nil
+	}
+	num, err := strconv.ParseFloat(input, 64)
+	if err != nil {
+		return bound{}, err
+	}
+	if math.IsNaN(num) || math.IsInf(num, 0) {
+		return bound{}, errors.New("must be a number")
+	}
+	return bound{value: num, set: true}, nil
+}
+
+// jsonValues decodes a JSON search body into the same values a submitted form has
+// Numbers and texts become single values, lists become multiple values (or a comma separated text
+// for brands and keywords), true becomes a checked checkbox while false and null are left out
+func jsonValues(body io.Reader) (url.Values, error) {
+	var data map[string]interface{}
+	if err := json.NewDecoder(body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("Invalid JSON body: %s", err.Error())
+	}
+	values := url.Values{}
+	for key, value := range data {
+		switch v := value.(type) {
+		case nil:
+		case bool:
+			if v {
+				values.Set(key, "on")
+			}
+		case float64:
+			values.Set(key, strconv.FormatFloat(v, 'f', -1, 64))
+		case string:
+			values.Set(key, v)
+		case []interface{}:
+			var list []string
+			for _, item := range v {
+				switch iv := item.(type) {
+				case float64:
+					list = append(list, strconv.FormatFloat(iv, 'f', -1, 64))
+				case string:
+					list = append(list, iv)
+				default:
+					return nil, fmt.Errorf("Invalid value in list %s", key)
+				}
+			}
+			if key == "categories" {
+				values[key] = list
+			} else {
+				values.Set(key, strings.Join(list, ","))
+			}
+		default:
+			return nil, fmt.Errorf("Invalid value for %s", key)
+		}
+	}
+	return values, nil
+}
@@ -15,26 +15,117 @@ import (
 // Product is a representation of an Amazon product
 // This contains basic properties needed to represent it
 type Product struct {
-	Name    string  `json:"name"`
-	Link    string  `json:"link"`
-	Price   float64 `json:"price"`
-	BSR     uint    `json:"bsr"`
-	Reviews uint    `json:"reviews"`
-	Length  float64 `json:"length"`
-	Width   float64 `json:"width"`
-	Height  float64 `json:"height"`
-	Weight  float64 `json:"weight"`
+	ASIN  string `json:"asin"`
+	Name  string `json:"name"`
+	Brand string `json:"brand"`
+	Link  string `json:"link"`
+	// Category is the main category the product was found in
+	Category string  `json:"category"`
+	Price    float64 `json:"price"`
+	BSR      uint    `json:"bsr"`
+	Reviews  uint    `json:"reviews"`
+	Rating   float64 `json:"rating"`
+	// Histogram holds the percentage of 5, 4, 3, 2 and 1 star ratings in this order
+	Histogram [5]uint `json:"histogram"`
+	Length    float64 `json:"length"`
+	Width     float64 `json:"width"`
+	Height    float64 `json:"height"`
+	Weight    float64 `json:"weight"`
+	Seller    string  `json:"seller"`
+	Fulfilled string  `json:"fulfilled"`
+	Offers    uint    `json:"offers"`
+	Prime     bool    `json:"prime"`
+	Image     string  `json:"image"`
+	Images    uint    `json:"images"`
+	Parent    string  `json:"parent"`
+	// Variations is the number of child ASINs
+	Variations uint `json:"variations"`
+	// Dimensions are the attributes the variations vary on (size, color)
+	Dimensions []string `json:"dimensions"`
+	// Sources tells for every field if it was found in the embedded JSON or in the DOM
+	Sources map[string]string `json:"sources"`
+	// Status tells for every field if it was found, missing or unparseable
+	Status map[string]fieldStatus `json:"status"`
+	// Unknown holds the criteria which could not be checked because of missing fields
+	Unknown []string `json:"unknown"`
+	// Rejections tell why the product failed the filters, they are only set on rejected products
+	Rejections []Rejection `json:"rejections,omitempty"`
+	// Score rates how promising the product is between 0 and 100
+	Score float64 `json:"score"`
+	// Sales are the estimated monthly units sold at the product rank
+	Sales uint `json:"sales"`
+	// Revenue is the estimated monthly revenue
+	Revenue float64 `json:"revenue"`
+	// SizeTier is the FBA size tier
+	SizeTier string `json:"sizeTier"`
+	// The fees, cost and profit are per unit sold
+	ReferralFee   float64 `json:"referralFee"`
+	FulfilmentFee float64 `json:"fulfilmentFee"`
+	Cost          float64 `json:"cost"`
+	Profit        float64 `json:"profit"`
+	// Margin and ROI are percentages
+	Margin float64 `json:"margin"`
+	ROI    float64 `json:"roi"`
 }
 
+// ouncesPerPound converts weights given in pounds to ounces
+const ouncesPerPound = 16
+
+// These are the ways a product can be sold and shipped to the customer
+const (
+	// soldByAmazon means Amazon itself is the seller of the product
+	soldByAmazon = "Amazon"
+	// fulfilledByAmazon means a third party seller ships the product from Amazon warehouses (FBA)
+	fulfilledByAmazon = "FBA"
+	// fulfilledByMerchant means a third party seller ships the product by himself (FBM)
+	fulfilledByMerchant = "FBM"
+)
+
 // findName gets the product name from the parsed document
-func findName(doc *goquery.Document) string {
+func findName(doc *goquery.Document) (string, fieldStatus) {
 	name := doc.Find("#productTitle").Text()
 	name = strings.TrimSpace(name)
-	return name
+	if name == "" {
+		log.Println("Error parsing name", name)
+		return name, statusMissing
+	}
+	return name, statusFound
+}
+
+// findBrand gets the product brand from the parsed document
+// It looks at the byline under the title first and then at the product details table
+func findBrand(doc *goquery.Document) (string, fieldStatus) {
+	// The byline holds something like 'Visit the Foo Store', 'Brand: Foo' or 'by Foo'
+	byline := strings.Join(strings.Fields(doc.Find("#bylineInfo").First().Text()), " ")
+	re := regexp.MustCompile("^(?:Visit\\s+the\\s+(.+?)\\s+Store|Brand:\\s*(.+)|by\\s+(.+))$")
+	if match := re.FindStringSubmatch(byline); match != nil {
+		for _, brand := range match[1:] {
+			if brand != "" {
+				return strings.TrimSpace(brand), statusFound
+			}
+		}
+	}
+	// Look for the brand row in the product details table
+	var brand string
+	re = regexp.MustCompile("^Brand(?:\\s+Name)?\\s*:?\\s*(.+)$")
+	doc.Find("#productDetails_techSpec_section_1 tr, #productOverview_feature_div tr, #detailBullets_feature_div li").EachWithBreak(func(i int, row *goquery.Selection) bool {
+		text := strings.Join(strings.Fields(row.Text()), " ")
+		if match := re.FindStringSubmatch(text); match != nil {
+			brand = strings.TrimSpace(match[1])
+			return false
+		}
+		return true
+	})
+	if brand == "" {
+		log.Println("Error parsing brand", byline)
+		return brand, statusMissing
+	}
+
+	return brand, statusFound
 }
 
 // findPrice gets the product price from the parsed document
-func findPrice(doc *goquery.Document) float64 {
+func findPrice(doc *goquery.Document) (float64, fieldStatus) {
 	var price float64
 	var strPrice string
 	// First look for the sale (discounted) price
@@ -46,13 +137,13 @@ func findPrice(doc *goquery.Document) float64 {
 	// If no price was found return price 0
 	if strPrice == "" {
 		log.Println("Error parsing price", strPrice)
-		return price
+		return price, statusMissing
 	}
 	// If the string does not start with $ return price 0
 	// This is because all prices on Amazon start with $
 	if !strings.HasPrefix(strPrice, "$") {
 		log.Println("Error parsing price", strPrice)
-		return price
+		return price, statusInvalid
 	}
 	// Replace any comma with empty space to avoid parse errors
 	strPrice = strings.Replace(strPrice, ",", "", -1)
@@ -61,7 +152,7 @@ func findPrice(doc *goquery.Document) float64 {
 		ps := strings.Split(strPrice, "-")
 		if len(ps) != 2 {
 			log.Println("Error parsing price range", strPrice)
-			return price
+			return price, statusInvalid
 		}
 		// Remove $ from the begining of the price and trim space
 		lowStrPrice := strings.TrimSpace(ps[0][1:])
@@ -70,12 +161,12 @@ func findPrice(doc *goquery.Document) float64 {
 		lowPrice, err := strconv.ParseFloat(lowStrPrice, 64)
 		if err != nil {
 			log.Printf("Error parsing low price %s: %s\n", lowStrPrice, err.Error())
-			return price
+			return price, statusInvalid
 		}
 		highPrice, err := strconv.ParseFloat(highStrPrice, 64)
 		if err != nil {
 			log.Printf("Error parsing high price %s: %s\n", highStrPrice, err.Error())
-			return price
+			return price, statusInvalid
 		}
 		price = (lowPrice + highPrice) / 2
 	} else {
@@ -87,56 +178,235 @@ func findPrice(doc *goquery.Document) float64 {
 		numPrice, err := strconv.ParseFloat(strPrice, 64)
 		if err != nil {
 			log.Printf("Error parsing price %s: %s\n", strPrice, err.Error())
-			return price
+			return price, statusInvalid
 		}
 		price = numPrice
 	}
 
-	return price
+	return price, statusFound
 }
 
 // findReviews gets the product number of reviews from the parsed document
-func findReviews(doc *goquery.Document) uint {
+// Amazon labels this number either as reviews or as ratings depending on the page layout
+func findReviews(doc *goquery.Document) (uint, fieldStatus) {
 	var reviews uint
-	strReviews := doc.Find("#acrCustomerReviewText").Text()
-	// If reviews text does not contain 'customer review' then it is something else
-	// This also acts for 'customer reviews'
-	if !strings.Contains(strReviews, "customer review") {
+	strReviews := doc.Find("#acrCustomerReviewText").First().Text()
+	// Replace any comma with empty space to avoid parse errors
+	strReviews = strings.Replace(strReviews, ",", "", -1)
+	// We will have something like '150 customer reviews' or '1234 global ratings'
+	re := regexp.MustCompile("([0-9]+)\\s+(?:global\\s+)?(?:customer\\s+)?(?:ratings?|reviews?)")
+	match := re.FindStringSubmatch(strReviews)
+	if match == nil {
 		log.Println("Error parsing reviews", strReviews)
-		return reviews
+		// Some text is present but it is not the number of reviews
+		if strings.TrimSpace(strReviews) != "" {
+			return reviews, statusInvalid
+		}
+		return reviews, statusMissing
+	}
+	// Parse the reviews number
+	numReviews, err := strconv.ParseUint(match[1], 10, 64)
+	if err != nil {
+		log.Printf("Error parsing reviews %s: %s\n", match[1], err.Error())
+		return reviews, statusInvalid
+	}
+
+	return uint(numReviews), statusFound
+}
+
+// findRating gets the product average star rating from the parsed document
+func findRating(doc *goquery.Document) (float64, fieldStatus) {
+	// The rating popover holds a title like '4.5 out of 5 stars'
+	strRating, ok := doc.Find("#acrPopover").First().Attr("title")
+	if !ok || strRating == "" {
+		// Fallback to the hidden text of the stars icon
+		strRating = doc.Find("#averageCustomerReviews .a-icon-alt").First().Text()
+	}
+	re := regexp.MustCompile("([0-9]+\\.?[0-9]*)\\s+out\\s+of\\s+5")
+	match := re.FindStringSubmatch(strRating)
+	if match == nil {
+		log.Println("Error parsing rating", strRating)
+		if strings.TrimSpace(strRating) != "" {
+			return 0, statusInvalid
+		}
+		return 0, statusMissing
+	}
+	numRating, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		log.Printf("Error parsing rating %s: %s\n", match[1], err.Error())
+		return 0, statusInvalid
+	}
+	// A rating can never exceed 5 stars
+	if numRating > 5 {
+		log.Println("Error parsing rating", strRating)
+		return 0, statusInvalid
+	}
+
+	return numRating, statusFound
+}
+
+// findHistogram gets the percentage of ratings for every number of stars from the parsed document
+// The result holds the 5 star percentage first and the 1 star percentage last
+func findHistogram(doc *goquery.Document) ([5]uint, fieldStatus) {
+	var hist [5]uint
+	// Every row looks like '5 star 73%' once the extra white space is removed
+	re := regexp.MustCompile("([1-5])\\s+stars?\\s*([0-9]+)\\s*%")
+	rows := doc.Find("#histogramTable tr, #histogramTable li")
+	found := false
+	rows.Each(func(i int, row *goquery.Selection) {
+		text := strings.Join(strings.Fields(row.Text()), " ")
+		match := re.FindStringSubmatch(text)
+		if match == nil {
+			return
+		}
+		stars, _ := strconv.Atoi(match[1])
+		percent, err := strconv.ParseUint(match[2], 10, 64)
+		if err != nil || percent > 100 {
+			log.Printf("Error parsing histogram %s\n", text)
+			return
+		}
+		hist[5-stars] = uint(percent)
+		found = true
+	})
+	if !found {
+		log.Println("Error parsing histogram")
+		return hist, statusMissing
+	}
+
+	return hist, statusFound
+}
+
+// findSeller gets the buy box seller and the way the product is fulfilled from the parsed document
+func findSeller(doc *goquery.Document) (string, string, fieldStatus) {
+	// Older layouts hold a sentence like 'Sold by Foo and Fulfilled by Amazon.'
+	merchant := strings.Join(strings.Fields(doc.Find("#merchant-info").First().Text()), " ")
+	if merchant != "" {
+		if strings.Contains(strings.ToLower(merchant), "sold by amazon") {
+			return "Amazon.com", soldByAmazon, statusFound
+		}
+		re := regexp.MustCompile("(?i)sold\\s+by\\s+(.+?)\\s+and\\s+fulfilled\\s+by\\s+amazon")
+		if match := re.FindStringSubmatch(merchant); match != nil {
+			return strings.TrimSpace(match[1]), fulfilledByAmazon, statusFound
+		}
+		re = regexp.MustCompile("(?i)(?:ships\\s+from\\s+and\\s+)?sold\\s+by\\s+(.+?)\\.?$")
+		if match := re.FindStringSubmatch(merchant); match != nil {
+			return strings.TrimSpace(match[1]), fulfilledByMerchant, statusFound
+		}
+	}
+	// Newer layouts hold a table with the 'Ships from' and 'Sold by' rows
+	shipsFrom := strings.TrimSpace(doc.Find("#tabular-buybox [tabular-attribute-name='Ships from'] .tabular-buybox-text").First().Text())
+	soldBy := strings.TrimSpace(doc.Find("#tabular-buybox [tabular-attribute-name='Sold by'] .tabular-buybox-text").First().Text())
+	if soldBy == "" {
+		log.Println("Error parsing seller", merchant)
+		return "", "", statusMissing
+	}
+	if strings.HasPrefix(soldBy, "Amazon") {
+		return soldBy, soldByAmazon, statusFound
 	}
+	if strings.HasPrefix(shipsFrom, "Amazon") {
+		return soldBy, fulfilledByAmazon, statusFound
+	}
+
+	return soldBy, fulfilledByMerchant, statusFound
+}
+
+// findOffers gets the number of offers (sellers) available for the product from the parsed document
+func findOffers(doc *goquery.Document) (uint, fieldStatus) {
+	strOffers := doc.Find("#olp_feature_div, #olp-upd-new, #olpLinkWidget_feature_div").Text()
+	strOffers = strings.Join(strings.Fields(strOffers), " ")
 	// Replace any comma with empty space to avoid parse errors
-	strReviews = strings.Replace(strReviews, ",", "", -1)
-	// If so, carry on with extracting the number of reviews
-	// We will have something like '150 customer reviews'
-	rs := strings.Split(strReviews, " ")
-	// Get the price from this text
-	strReviews = strings.TrimSpace(rs[0])
-	// Parse the price number
-	numReviews, err := strconv.ParseUint(strReviews, 10, 64)
+	strOffers = strings.Replace(strOffers, ",", "", -1)
+	// We will have something like 'New (5) from $12.99' or '5 new from $12.99'
+	re := regexp.MustCompile("(?i)\\(([0-9]+)\\)\\s+from|([0-9]+)\\s+(?:new|used|offers?)")
+	match := re.FindStringSubmatch(strOffers)
+	if match == nil {
+		// No other offers are listed, so only the buy box seller is left
+		if doc.Find("#merchant-info, #tabular-buybox").Length() > 0 {
+			return 1, statusFound
+		}
+		log.Println("Error parsing offers", strOffers)
+		return 0, statusMissing
+	}
+	strNum := match[1]
+	if strNum == "" {
+		strNum = match[2]
+	}
+	numOffers, err := strconv.ParseUint(strNum, 10, 64)
 	if err != nil {
-		log.Printf("Error parsing reviews %s: %s\n", strReviews, err.Error())
-		return reviews
+		log.Printf("Error parsing offers %s: %s\n", strNum, err.Error())
+		return 0, statusInvalid
+	}
+
+	return uint(numOffers), statusFound
+}
+
+// findPrime checks if the product is eligible for Prime shipping
+func findPrime(doc *goquery.Document) bool {
+	badges := doc.Find("#priceBadging_feature_div .a-icon-prime, #buybox .a-icon-prime, #primeBadge, #prime-badge")
+	return badges.Length() > 0
+}
+
+// findASIN extracts the product ASIN from the product link
+// Links look like 'https://www.amazon.com/Product-Name/dp/B01ABCDEFG'
+func findASIN(link string) (string, fieldStatus) {
+	re := regexp.MustCompile("/dp/([A-Z0-9]{10})")
+	match := re.FindStringSubmatch(link)
+	if match == nil {
+		log.Println("Error parsing ASIN", link)
+		return "", statusMissing
+	}
+	return match[1], statusFound
+}
+
+// findImages gets the main image url and the number of images of the product from the parsed document
+func findImages(doc *goquery.Document) (string, uint, fieldStatus) {
+	img := doc.Find("#landingImage, #imgBlkFront").First()
+	// The high resolution image is preferred over the displayed one
+	image, ok := img.Attr("data-old-hires")
+	if !ok || image == "" {
+		image, _ = img.Attr("src")
+	}
+	if image == "" {
+		log.Println("Error parsing image")
+		return "", 0, statusMissing
 	}
+	// Every image has its own thumbnail on the left side of the main image
+	count := uint(doc.Find("#altImages li.imageThumbnail").Length())
+	if count == 0 {
+		count = 1
+	}
+	return image, count, statusFound
+}
 
-	return uint(numReviews)
+// findVariations gets the number of child ASINs and the variation dimensions from the twister selectors
+// The twister script holding the parent ASIN is read with the structured data so it is not parsed again here
+// Products without variations return no children
+func findVariations(doc *goquery.Document) (uint, []string) {
+	// Every child ASIN is an option of the size/color selectors
+	children := uint(doc.Find("#twister li[data-defaultasin]").Length())
+	var dims []string
+	doc.Find("#twister [id^='variation_']").Each(func(i int, sel *goquery.Selection) {
+		id, _ := sel.Attr("id")
+		dims = append(dims, strings.TrimSuffix(strings.TrimPrefix(id, "variation_"), "_name"))
+	})
+	return children, dims
 }
 
 // findDimensions gets the product dimensions from the parsed document
 // It searches into the HTML container for a certain pattern and returns all dimensions
-func findDimensions(container string) (float64, float64, float64) {
+func findDimensions(container string) (float64, float64, float64, fieldStatus) {
 	// Compute the regex to find the dimensions pattern in the container
 	re := regexp.MustCompile("[0-9]+\\.?[0-9]*\\s+x\\s+[0-9]+\\.?[0-9]*\\s+x\\s+[0-9]+\\.?[0-9]*\\s+inches")
 	// We return something like '12.3 x 14 x 23 inches'
 	strDim := re.FindString(container)
 	if strDim == "" {
 		log.Println("Error parsing dimensions", strDim)
-		return 0, 0, 0
+		return 0, 0, 0, statusMissing
 	}
 	ds := strings.Split(strDim, "x")
 	if len(ds) != 3 {
 		log.Println("Error parsing dimensions", strDim)
-		return 0, 0, 0
+		return 0, 0, 0, statusInvalid
 	}
 	// Extract all 3 dimensions as strings first
 	strLength := strings.TrimSpace(ds[0])
@@ -151,27 +421,27 @@ func findDimensions(container string) (float64, float64, float64) {
 	numLength, err := strconv.ParseFloat(strLength, 64)
 	if err != nil {
 		log.Printf("Error parsing length %s: %s\n", strLength, err.Error())
-		return 0, 0, 0
+		return 0, 0, 0, statusInvalid
 	}
 
 	numWidth, err := strconv.ParseFloat(strWidth, 64)
 	if err != nil {
 		log.Printf("Error parsing width %s: %s\n", strWidth, err.Error())
-		return 0, 0, 0
+		return 0, 0, 0, statusInvalid
 	}
 
 	numHeight, err := strconv.ParseFloat(strHeight, 64)
 	if err != nil {
 		log.Printf("Error parsing height %s: %s\n", strHeight, err.Error())
-		return 0, 0, 0
+		return 0, 0, 0, statusInvalid
 	}
 
-	return numLength, numWidth, numHeight
+	return numLength, numWidth, numHeight, statusFound
 }
 
 // findWeight gets the product weight from the parsed document
 // It searches into the HTML container for a certain pattern and returns the weight
-func findWeight(container string) float64 {
+func findWeight(container string) (float64, fieldStatus) {
 	var strWeight string
 	re := regexp.MustCompile("[0-9]+\\.?[0-9]*\\s+(ounces|pounds)")
 	// We return something like '[23.45 ounces|pounds, 24 ounces|pounds]'
@@ -185,7 +455,7 @@ func findWeight(container string) float64 {
 
 	if strWeight == "" {
 		log.Println("Error parsing weight", strWeight)
-		return 0
+		return 0, statusMissing
 	}
 	// Split the found string
 	ws := strings.Split(strWeight, " ")
@@ -197,20 +467,27 @@ func findWeight(container string) float64 {
 	numWeight, err := strconv.ParseFloat(strWeight, 64)
 	if err != nil {
 		log.Printf("Error parsing weight %s: %s\n", strWeight, err.Error())
-		return 0
+		return 0, statusInvalid
 	}
 
-	return numWeight
+	// The weight is always kept in ounces so convert it when given in pounds
+	if ws[len(ws)-1] == "pounds" {
+		numWeight *= ouncesPerPound
+	}
+
+	return numWeight, statusFound
 }
 
-func findBSR(container string) uint {
+// findBSR gets the product best sellers rank from the parsed document
+// It searches into the HTML container for a certain pattern and returns the rank
+func findBSR(container string) (uint, fieldStatus) {
 	re := regexp.MustCompile("#[0-9]+\\.?[0-9]*\\s+in\\s+.+\\s+")
 	// We return something like '#45 in Kitchen (See Top 100 Kitchen)'
 	strBSR := re.FindString(container)
 
 	if strBSR == "" {
 		log.Println("Error parsing BSR", strBSR)
-		return 0
+		return 0, statusMissing
 	}
 	// Split the string
 	bs := strings.Split(strBSR, " ")
@@ -218,7 +495,7 @@ func findBSR(container string) uint {
 	strBSR = strings.TrimSpace(bs[0])
 	if !strings.HasPrefix(strBSR, "#") {
 		log.Println("Error parsing BSR", strBSR)
-		return 0
+		return 0, statusInvalid
 	}
 	// Remove the first character which is #
 	strBSR = strBSR[1:]
@@ -228,10 +505,10 @@ func findBSR(container string) uint {
 	numBSR, err := strconv.ParseUint(strBSR, 10, 64)
 	if err != nil {
 		log.Printf("Error parsing BSR %s: %s\n", strBSR, err.Error())
-		return 0
+		return 0, statusInvalid
 	}
 
-	return uint(numBSR)
+	return uint(numBSR), statusFound
 }
 
 // getProduct fetches the product found at the given link
@@ -240,7 +517,7 @@ func getProduct(link string, client *http.Client, done <-chan struct{}) (Product
 	req, err := http.NewRequest(http.MethodGet, link, nil)
 
 	if err != nil {
-		log.Fatal(err)
+		return Product{}, fmt.Errorf("Request error at url %s: %s", link, err.Error())
 	}
 	// Set proper headers to simulate a request coming from a real browser
 	req.Header.Set("Accept", headers["Accept"])
@@ -268,82 +545,84 @@ func getProduct(link string, client *http.Client, done <-chan struct{}) (Product
 		}
 		defer res.Body.Close()
 
+		// Start with the data embedded in the page scripts
+		prod, found := findStructured(doc)
+		prod.Link = link
+		prod.Sources = make(map[string]string)
+		prod.Status = make(map[string]fieldStatus)
+		for field := range found {
+			prod.Sources[field] = sourceJSON
+			prod.Status[field] = statusFound
+		}
+		// fallback parses the visible page text for the fields missing from the embedded data
+		fallback := func(parse func() fieldStatus, fields ...string) {
+			missing := false
+			for _, field := range fields {
+				if !found[field] {
+					missing = true
+				}
+			}
+			if !missing {
+				return
+			}
+			status := parse()
+			for _, field := range fields {
+				if !found[field] {
+					prod.Sources[field] = sourceDOM
+					prod.Status[field] = status
+				}
+			}
+		}
+
 		// Find product attributes
-		name := findName(doc)
-		price := findPrice(doc)
-		reviews := findReviews(doc)
+		var status fieldStatus
+		fallback(func() fieldStatus { prod.ASIN, status = findASIN(link); return status }, "asin")
+		fallback(func() fieldStatus { prod.Name, status = findName(doc); return status }, "name")
+		fallback(func() fieldStatus { prod.Brand, status = findBrand(doc); return status }, "brand")
+		fallback(func() fieldStatus { prod.Price, status = findPrice(doc); return status }, "price")
+		fallback(func() fieldStatus { prod.Reviews, status = findReviews(doc); return status }, "reviews")
+		fallback(func() fieldStatus { prod.Rating, status = findRating(doc); return status }, "rating")
+		fallback(func() fieldStatus { prod.Histogram, status = findHistogram(doc); return status }, "histogram")
+		fallback(func() fieldStatus { prod.Seller, prod.Fulfilled, status = findSeller(doc); return status }, "seller", "fulfilled")
+		fallback(func() fieldStatus { prod.Offers, status = findOffers(doc); return status }, "offers")
+		// The Prime badge is either there or not so this field is always found
+		fallback(func() fieldStatus { prod.Prime = findPrime(doc); return statusFound }, "prime")
+		fallback(func() fieldStatus {
+			image, images, status := findImages(doc)
+			if !found["image"] {
+				prod.Image = image
+			}
+			if !found["images"] {
+				prod.Images = images
+			}
+			return status
+		}, "image", "images")
+		// Products without variations are valid so these fields are always found
+		fallback(func() fieldStatus {
+			variations, dimensions := findVariations(doc)
+			if !found["variations"] {
+				prod.Variations = variations
+			}
+			if !found["dimensions"] {
+				prod.Dimensions = dimensions
+			}
+			return statusFound
+		}, "parent", "variations", "dimensions")
 
 		// Get the container from the HTML document
 		container := doc.Find("#dp-container").Text()
 		// Replace all , with empty space to easily find every number
 		container = strings.Replace(container, ",", "", -1)
 		// Fetch all 3 dimensions
-		length, width, height := findDimensions(container)
+		fallback(func() fieldStatus {
+			prod.Length, prod.Width, prod.Height, status = findDimensions(container)
+			return status
+		}, "length", "width", "height")
 		// Fetch product shipping weight
-		weight := findWeight(container)
+		fallback(func() fieldStatus { prod.Weight, status = findWeight(container); return status }, "weight")
 		// Fetch BSR
-		bsr := findBSR(container)
-
-		prod := Product{
-			Name:    name,
-			Link:    link,
-			Price:   price,
-			BSR:     bsr,
-			Reviews: reviews,
-			Length:  length,
-			Width:   width,
-			Height:  height,
-			Weight:  weight,
-		}
+		fallback(func() fieldStatus { prod.BSR, status = findBSR(container); return status }, "bsr")
 
 		return prod, nil
 	}
 }
-
-// isValid checks if a product is valid correspondign to the user selected options
-func (prod *Product) isValid(opts options) bool {
-	// Calculate corrected options with tolerance
-	minPrice := (1 - opts.tolerance/100) * opts.minPrice
-	maxPrice := (1 + opts.tolerance/100) * opts.maxPrice
-
-	minBSR := (1 - opts.tolerance/100) * float64(opts.minBSR)
-	maxBSR := (1 + opts.tolerance/100) * float64(opts.maxBSR)
-
-	minReviews := (1 - opts.tolerance/100) * float64(opts.minReviews)
-	maxReviews := (1 + opts.tolerance/100) * float64(opts.maxReviews)
-
-	maxLength := (1 + opts.tolerance/100) * opts.maxLength
-	maxWidth := (1 + opts.tolerance/100) * opts.maxWidth
-	maxHeight := (1 + opts.tolerance/100) * opts.maxHeight
-	maxWeight := (1 + opts.tolerance/100) * opts.maxWeight
-
-	if prod.Price < minPrice || prod.Price > maxPrice {
-		return false
-	}
-
-	if float64(prod.BSR) < minBSR || float64(prod.BSR) > maxBSR {
-		return false
-	}
-
-	if float64(prod.Reviews) < minReviews || float64(prod.Reviews) > maxReviews {
-		return false
-	}
-
-	if prod.Length > maxLength {
-		return false
-	}
-
-	if prod.Width > maxWidth {
-		return false
-	}
-
-	if prod.Height > maxHeight {
-		return false
-	}
-
-	if prod.Weight > maxWeight {
-		return false
-	}
-
-	return true
-}
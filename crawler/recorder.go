@@ -0,0 +1,101 @@
+package crawler
+
+import (
+	"errors"
+	"log"
+	"net/url"
+	"time"
+)
+
+// Recorder saves the crawls and every product they fetch, valid or not
+// This way the products outlive the browser session and their history can be built
+type Recorder interface {
+	StartRun(id string, started time.Time, options url.Values) error
+	SaveProduct(run string, prod Product, valid bool) error
+	FinishRun(id string, rep Report) error
+}
+
+// Hooks are called as the crawl goes so the application can react, nil hooks are skipped
+type Hooks struct {
+	// Found is called for every product which passed the filters
+	Found func(run string, prod Product)
+	// Finished is called at the end of the crawl with the reason it failed if it did
+	Finished func(run string, rep Report, err error)
+}
+
+// errNoPages fails a crawl which could not load a single category page, Amazon probably blocked it
+var errNoPages = errors.New("no category page could be loaded")
+
+// runIDFormat makes run ids which sort in the order the runs were started
+const runIDFormat = "20060102T150405.000000"
+
+// startRun gives the crawl a new id and records it along with the search options
+func (crw *Crawler) startRun() {
+	started := time.Now().UTC()
+	crw.mu.Lock()
+	crw.runID = started.Format(runIDFormat)
+	crw.report.Run = crw.runID
+	crw.mu.Unlock()
+	if crw.Recorder == nil {
+		return
+	}
+	if err := crw.Recorder.StartRun(crw.runID, started, crw.values); err != nil {
+		log.Println("Error recording run:", err)
+	}
+}
+
+// recordSnapshot saves a fetched product
+func (crw *Crawler) recordSnapshot(prod Product, v Verdict) {
+	if crw.Recorder == nil {
+		return
+	}
+	if err := crw.Recorder.SaveProduct(crw.runID, prod, v.Valid); err != nil {
+		log.Println("Error recording product:", err)
+	}
+}
+
+// recordFound tells the hooks about a product which passed the filters
+func (crw *Crawler) recordFound(prod Product) {
+	if crw.Hooks.Found != nil {
+		crw.Hooks.Found(crw.runID, prod)
+	}
+}
+
+// finishRun records the report of the crawl and tells the hooks whether it failed
+// A crawl stopped by the user did not fail
+func (crw *Crawler) finishRun() {
+	rep := crw.Report()
+	if crw.Recorder != nil {
+		if err := crw.Recorder.FinishRun(crw.runID, rep); err != nil {
+			log.Println("Error recording run:", err)
+		}
+	}
+	if crw.Hooks.Finished != nil {
+		crw.Hooks.Finished(crw.runID, rep, crw.Err())
+	}
+}
+
+// Err returns the reason the last crawl failed, nil when it did not
+func (crw *Crawler) Err() error {
+	if crw.Report().Pages == 0 && !crw.stopped() {
+		return errNoPages
+	}
+	return nil
+}
+
+// stopped tells if the crawl was stopped before its end
+func (crw *Crawler) stopped() bool {
+	select {
+	case <-crw.Done:
+		return true
+	default:
+		return false
+	}
+}
+
+// RunID returns the id of the current (or last) crawl
+func (crw *Crawler) RunID() string {
+	crw.mu.Lock()
+	defer crw.mu.Unlock()
+	return crw.runID
+}
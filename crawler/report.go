@@ -0,0 +1,124 @@
+package crawler
+
+import (
+	"time"
+)
+
+// Report holds the parse quality statistics of a crawl
+// A sudden rise of missing fields usually means Amazon changed its page layout
+type Report struct {
+	// Run is the id the crawl is recorded with
+	Run      string    `json:"run"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	// Pages is the number of category pages parsed
+	Pages uint `json:"pages"`
+	// Products is the number of product pages parsed and Errors the number of pages which failed to load
+	Products uint `json:"products"`
+	Errors   uint `json:"errors"`
+	// Found is the number of products which passed all the filters
+	Found uint `json:"found"`
+	// Missing and Invalid count for every field the products where it was missing or unparseable
+	Missing map[string]uint `json:"missing"`
+	Invalid map[string]uint `json:"invalid"`
+	// Rejected counts for every criterion the products it rejected
+	Rejected map[string]uint `json:"rejected"`
+	// Expression is the filter expression built from the search criteria which every product is checked against
+	Expression string `json:"expression"`
+}
+
+// Message is sent to the frontend through the websocket connection
+// The type tells the frontend how to handle the data (product, report, ranking)
+type Message struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// resetReport starts a fresh report for a new crawl
+func (crw *Crawler) resetReport() {
+	crw.mu.Lock()
+	defer crw.mu.Unlock()
+	crw.results = nil
+	crw.report = Report{
+		Started:  time.Now(),
+		Missing:  make(map[string]uint),
+		Invalid:  make(map[string]uint),
+		Rejected: make(map[string]uint),
+		// The options are set before the crawl starts and do not change while it runs
+		Expression: crw.opts.expression(),
+	}
+}
+
+// recordPage counts a parsed category page
+func (crw *Crawler) recordPage() {
+	crw.mu.Lock()
+	defer crw.mu.Unlock()
+	crw.report.Pages++
+}
+
+// recordError counts a product page which could not be fetched or parsed
+func (crw *Crawler) recordError() {
+	crw.mu.Lock()
+	defer crw.mu.Unlock()
+	crw.report.Errors++
+}
+
+// recordProduct counts the fields the product is missing and the criteria that rejected it
+// Valid products are kept to be ranked at the end of the crawl
+func (crw *Crawler) recordProduct(prod Product, v Verdict) {
+	crw.mu.Lock()
+	defer crw.mu.Unlock()
+	crw.report.Products++
+	for field, status := range prod.Status {
+		switch status {
+		case statusMissing:
+			crw.report.Missing[field]++
+		case statusInvalid:
+			crw.report.Invalid[field]++
+		}
+	}
+	if v.Valid {
+		crw.report.Found++
+		crw.results = append(crw.results, prod)
+	}
+	for _, rej := range v.Rejections {
+		crw.report.Rejected[rej.Criterion]++
+	}
+}
+
+// finishReport marks the end of the crawl
+func (crw *Crawler) finishReport() {
+	crw.mu.Lock()
+	defer crw.mu.Unlock()
+	crw.report.Finished = time.Now()
+}
+
+// Report returns a copy of the statistics of the current (or last) crawl
+func (crw *Crawler) Report() Report {
+	crw.mu.Lock()
+	defer crw.mu.Unlock()
+	rep := crw.report
+	rep.Missing = copyCounts(crw.report.Missing)
+	rep.Invalid = copyCounts(crw.report.Invalid)
+	rep.Rejected = copyCounts(crw.report.Rejected)
+	return rep
+}
+
+// Ranking returns the products found by the current (or last) crawl with the most promising first
+func (crw *Crawler) Ranking() []Product {
+	crw.mu.Lock()
+	defer crw.mu.Unlock()
+	prods := make([]Product, len(crw.results))
+	copy(prods, crw.results)
+	rank(prods)
+	return prods
+}
+
+// copyCounts makes a copy of a counts map so it can be read without holding the lock
+func copyCounts(m map[string]uint) map[string]uint {
+	c := make(map[string]uint, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
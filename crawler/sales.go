@@ -0,0 +1,106 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+)
+
+// curvePoint maps a best sellers rank to the units sold in a month at that rank
+type curvePoint struct {
+	BSR   float64 `json:"bsr"`
+	Units float64 `json:"units"`
+}
+
+// SalesCurves holds for every category slug the points of its BSR to monthly sales curve
+// The curve under the "default" key is used for the categories without their own curve
+type SalesCurves map[string][]curvePoint
+
+// defaultCategory is the key of the curve and referral fee used when a category has none
+const defaultCategory = "default"
+
+// defaultSalesCurves is a rough curve for the US marketplace used when no curve file is loaded
+var defaultSalesCurves = SalesCurves{
+	defaultCategory: {
+		{BSR: 1, Units: 60000},
+		{BSR: 10, Units: 15000},
+		{BSR: 100, Units: 3000},
+		{BSR: 1000, Units: 600},
+		{BSR: 10000, Units: 100},
+		{BSR: 100000, Units: 10},
+		{BSR: 1000000, Units: 1},
+	},
+}
+
+// LoadSalesCurves reads the sales curves from a JSON file
+// The file looks like '{"default": [{"bsr": 1, "units": 60000}, ...], "kitchen": [...]}'
+// Every curve needs at least 2 points with a positive BSR and units, the points are sorted by BSR
+// and no two of them may have the same BSR since the curve is interpolated between them
+func LoadSalesCurves(file string) (SalesCurves, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var curves SalesCurves
+	if err := json.Unmarshal(data, &curves); err != nil {
+		return nil, fmt.Errorf("invalid sales curves file %s: %s", file, err)
+	}
+	for cat, points := range curves {
+		if len(points) < 2 {
+			return nil, fmt.Errorf("sales curve %s must have at least 2 points", cat)
+		}
+		for _, p := range points {
+			if p.BSR <= 0 || p.Units <= 0 {
+				return nil, fmt.Errorf("sales curve %s must have a positive BSR and units in every point", cat)
+			}
+		}
+		sort.Slice(points, func(i, j int) bool { return points[i].BSR < points[j].BSR })
+		for i := 1; i < len(points); i++ {
+			if points[i].BSR == points[i-1].BSR {
+				return nil, fmt.Errorf("sales curve %s has more than one point with BSR %g", cat, points[i].BSR)
+			}
+		}
+	}
+	if _, ok := curves[defaultCategory]; !ok {
+		curves[defaultCategory] = defaultSalesCurves[defaultCategory]
+	}
+	return curves, nil
+}
+
+// estimate returns the monthly units sold at the rank in the category
+// Sales fall roughly as a power of the rank so the points are interpolated on a log-log scale
+// Ranks outside the curve follow the slope of its first or last segment
+func (curves SalesCurves) estimate(cat string, bsr uint) float64 {
+	points, ok := curves[cat]
+	if !ok {
+		points = curves[defaultCategory]
+	}
+	if len(points) < 2 || bsr == 0 {
+		return 0
+	}
+	// Find the segment holding the rank
+	i := sort.Search(len(points), func(i int) bool { return points[i].BSR >= float64(bsr) })
+	if i == 0 {
+		i = 1
+	} else if i == len(points) {
+		i = len(points) - 1
+	}
+	a, b := points[i-1], points[i]
+	slope := math.Log(b.Units/a.Units) / math.Log(b.BSR/a.BSR)
+	return a.Units * math.Pow(float64(bsr)/a.BSR, slope)
+}
+
+// estimateSales sets the estimated monthly units and revenue of the product
+// The estimate is only made when the rank was found
+func (prod *Product) estimateSales(curves SalesCurves, cat string) {
+	if prod.Status["bsr"] != statusFound {
+		return
+	}
+	if curves == nil {
+		curves = defaultSalesCurves
+	}
+	prod.Sales = uint(math.Round(curves.estimate(cat, prod.BSR)))
+	prod.Revenue = math.Round(100*float64(prod.Sales)*prod.Price) / 100
+}
@@ -0,0 +1,97 @@
+package crawler
+
+import (
+	"math"
+	"sort"
+)
+
+// weights tells how much every factor counts in the product score
+// A weight of 0 leaves the factor out of the score
+type weights struct {
+	bsr         float64
+	reviews     float64
+	price       float64
+	size        float64
+	rating      float64
+	competition float64
+}
+
+// defaultWeights favours best sellers with few reviews and few competing sellers
+var defaultWeights = weights{
+	bsr:         3,
+	reviews:     2,
+	price:       1,
+	size:        1,
+	rating:      1,
+	competition: 2,
+}
+
+// These limits are used to scale every factor between 0 (worst) and 1 (best)
+const (
+	// scoreMaxBSR is the rank from which a product gets no BSR points at all
+	scoreMaxBSR = 1000000
+	// scoreMaxReviews is the number of reviews from which a product gets no review points at all
+	scoreMaxReviews = 10000
+	// scoreMinPrice and scoreMaxPrice hold the price range which gets all the price points
+	scoreMinPrice = 15
+	scoreMaxPrice = 50
+	// scoreMaxVolume (cubic inches) and scoreMaxWeight (ounces) are the limits of a large standard size product
+	scoreMaxVolume = 18 * 14 * 8
+	scoreMaxWeight = 20 * ouncesPerPound
+	// scoreMaxSellers is the number of sellers from which a product gets no competition points at all
+	scoreMaxSellers = 20
+)
+
+// score rates how promising the product is between 0 and 100
+// Every factor is scaled between 0 and 1 and the weighted average is taken
+// Factors depending on fields which were not found are left out
+func (prod *Product) score(w weights) float64 {
+	var total, sum float64
+	add := func(weight, factor float64, fields ...string) {
+		for _, field := range fields {
+			if prod.Status[field] != statusFound {
+				return
+			}
+		}
+		total += weight
+		sum += weight * math.Max(0, math.Min(1, factor))
+	}
+
+	// A lower rank means more sales, the scale is logarithmic since ranks span many orders of magnitude
+	if prod.BSR > 0 {
+		add(w.bsr, 1-math.Log10(float64(prod.BSR))/math.Log10(scoreMaxBSR), "bsr")
+	}
+	// Fewer reviews mean it is easier to compete with the existing listings
+	add(w.reviews, 1-math.Log10(1+float64(prod.Reviews))/math.Log10(1+scoreMaxReviews), "reviews")
+	// Prices inside the sweet spot get all the points and the rest lose points the further they are
+	price := 1.0
+	if prod.Price < scoreMinPrice {
+		price = prod.Price / scoreMinPrice
+	} else if prod.Price > scoreMaxPrice {
+		price = scoreMaxPrice / prod.Price
+	}
+	add(w.price, price, "price")
+	// Smaller and lighter products are cheaper to ship and store
+	volume := prod.Length * prod.Width * prod.Height
+	add(w.size, 1-(volume/scoreMaxVolume+prod.Weight/scoreMaxWeight)/2, "length", "width", "height", "weight")
+	add(w.rating, prod.Rating/maxRating, "rating")
+	// Amazon itself as a seller leaves no room for competition
+	competition := 1 - float64(prod.Offers)/scoreMaxSellers
+	if prod.Fulfilled == soldByAmazon {
+		competition = 0
+	}
+	add(w.competition, competition, "offers")
+
+	if total == 0 {
+		return 0
+	}
+	// Round to 1 decimal to keep the frontend readable
+	return math.Round(1000*sum/total) / 10
+}
+
+// rank sorts the products by score with the most promising first
+func rank(prods []Product) {
+	sort.SliceStable(prods, func(i, j int) bool {
+		return prods[i].Score > prods[j].Score
+	})
+}
@@ -0,0 +1,63 @@
+package crawler
+
+import "fmt"
+
+// fieldStatus tells if a product field could be extracted from the page
+type fieldStatus string
+
+// These are the states of an extracted product field
+const (
+	// statusFound means the field was found and parsed successfully
+	statusFound fieldStatus = "found"
+	// statusMissing means the field was not present in the page
+	statusMissing fieldStatus = "missing"
+	// statusInvalid means the field was present but its value could not be parsed
+	statusInvalid fieldStatus = "invalid"
+)
+
+// Found tells if the field was extracted from the product page
+func (prod *Product) Found(field string) bool {
+	return prod.Status[field] == statusFound
+}
+
+// policy tells what to do with a product when a field needed by a criterion is not found
+type policy string
+
+// These are the available policies for products with missing fields
+const (
+	// policyReject drops the product
+	policyReject policy = "reject"
+	// policyAccept skips the criterion as if the product met it
+	policyAccept policy = "accept"
+	// policyUnknown skips the criterion but marks it as unknown on the product
+	policyUnknown policy = "unknown"
+)
+
+// criterionFields maps every filter criterion to the product fields it needs
+var criterionFields = map[string][]string{
+	"price":   {"price"},
+	"bsr":     {"bsr"},
+	"reviews": {"reviews"},
+	"rating":  {"rating"},
+	"size":    {"length", "width", "height"},
+	"weight":  {"weight"},
+	"sellers": {"offers"},
+	"sales":   {"bsr"},
+	"revenue": {"bsr", "price"},
+	"profit":  {"price", "length", "width", "height", "weight"},
+	"margin":  {"price", "length", "width", "height", "weight"},
+	"roi":     {"price", "length", "width", "height", "weight"},
+}
+
+// parsePolicy converts the user input to a policy
+// An empty input falls back to treating missing fields as unknown
+func parsePolicy(input string) (policy, error) {
+	switch p := policy(input); p {
+	case "":
+		return policyUnknown, nil
+	case policyReject, policyAccept, policyUnknown:
+		return p, nil
+	default:
+		return "", fmt.Errorf("must be one of %s, %s or %s", policyReject, policyAccept, policyUnknown)
+	}
+}
@@ -0,0 +1,232 @@
+package crawler
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// These are the sources a product field can be extracted from
+const (
+	// sourceJSON means the value was found in the data embedded in the page scripts
+	sourceJSON = "json"
+	// sourceDOM means the value was parsed from the visible page text
+	sourceDOM = "dom"
+)
+
+const (
+	// buyBoxStateKey is the key of the state block holding the buy box prices
+	buyBoxStateKey = "twister-plus-buying-options-price-data"
+	// buyBoxGroup is the group of the buying options shown in the buy box
+	buyBoxGroup = "desktop_buybox_group_1"
+)
+
+// findStructured extracts the product fields from the data embedded in the page scripts
+// This data is more stable than the visible text so it is tried before the DOM
+// It returns the partially filled product and the set of fields that were found
+func findStructured(doc *goquery.Document) (Product, map[string]bool) {
+	var prod Product
+	found := make(map[string]bool)
+
+	// Schema.org product data looks like '{"@type":"Product","name":"Foo","offers":{"price":"12.99"}}'
+	doc.Find("script[type='application/ld+json']").Each(func(i int, sel *goquery.Selection) {
+		var data interface{}
+		if err := json.Unmarshal([]byte(sel.Text()), &data); err != nil {
+			return
+		}
+		if obj := findJSONProduct(data); obj != nil {
+			fromLDJSON(obj, &prod, found)
+		}
+	})
+
+	// Amazon state blocks look like '<script type="a-state" data-a-state='{"key":"..."}'>{...}</script>'
+	// Only the buy box price block is read since the others hold the prices of ads and other products too
+	doc.Find("script[type='a-state']").Each(func(i int, sel *goquery.Selection) {
+		var state struct {
+			Key string `json:"key"`
+		}
+		if err := json.Unmarshal([]byte(sel.AttrOr("data-a-state", "")), &state); err != nil || state.Key != buyBoxStateKey {
+			return
+		}
+		var data map[string][]map[string]interface{}
+		if err := json.Unmarshal([]byte(sel.Text()), &data); err != nil {
+			return
+		}
+		fromState(data, &prod, found)
+	})
+
+	// The twister script holds the variation data inside a javascript object
+	doc.Find("script").Each(func(i int, sel *goquery.Selection) {
+		text := sel.Text()
+		if strings.Contains(text, "dimensionValuesDisplayData") {
+			fromTwister(text, &prod, found)
+		}
+	})
+
+	return prod, found
+}
+
+// findJSONProduct looks for the object of type Product in the decoded ld+json data
+// The data can be a single object, a list of objects or an object holding a @graph list
+func findJSONProduct(data interface{}) map[string]interface{} {
+	switch v := data.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if obj := findJSONProduct(item); obj != nil {
+				return obj
+			}
+		}
+	case map[string]interface{}:
+		if t, _ := v["@type"].(string); t == "Product" {
+			return v
+		}
+		if graph, ok := v["@graph"]; ok {
+			return findJSONProduct(graph)
+		}
+	}
+	return nil
+}
+
+// fromLDJSON fills the product with the fields found in a schema.org product object
+func fromLDJSON(obj map[string]interface{}, prod *Product, found map[string]bool) {
+	if name, ok := obj["name"].(string); ok && name != "" {
+		prod.Name = strings.TrimSpace(name)
+		found["name"] = true
+	}
+	// The brand is either a plain string or an object with a name
+	switch brand := obj["brand"].(type) {
+	case string:
+		if brand != "" {
+			prod.Brand = strings.TrimSpace(brand)
+			found["brand"] = true
+		}
+	case map[string]interface{}:
+		if name, ok := brand["name"].(string); ok && name != "" {
+			prod.Brand = strings.TrimSpace(name)
+			found["brand"] = true
+		}
+	}
+	// The image is either a plain url or a list of urls
+	switch image := obj["image"].(type) {
+	case string:
+		if image != "" {
+			prod.Image = image
+			found["image"] = true
+		}
+	case []interface{}:
+		if len(image) > 0 {
+			if url, ok := image[0].(string); ok {
+				prod.Image = url
+				prod.Images = uint(len(image))
+				found["image"] = true
+				found["images"] = true
+			}
+		}
+	}
+	// Offers are either a single offer or a list of offers
+	offers := obj["offers"]
+	if list, ok := offers.([]interface{}); ok && len(list) > 0 {
+		offers = list[0]
+	}
+	if offer, ok := offers.(map[string]interface{}); ok {
+		if price, ok := jsonNumber(offer["price"]); ok && price > 0 {
+			prod.Price = price
+			found["price"] = true
+		} else {
+			// An aggregate offer holds a price range so we take the middle of it
+			low, okLow := jsonNumber(offer["lowPrice"])
+			high, okHigh := jsonNumber(offer["highPrice"])
+			if okLow && okHigh && low > 0 {
+				prod.Price = (low + high) / 2
+				found["price"] = true
+			}
+		}
+		if count, ok := jsonNumber(offer["offerCount"]); ok && count > 0 {
+			prod.Offers = uint(count)
+			found["offers"] = true
+		}
+	}
+	if rating, ok := obj["aggregateRating"].(map[string]interface{}); ok {
+		if value, ok := jsonNumber(rating["ratingValue"]); ok && value <= 5 {
+			prod.Rating = value
+			found["rating"] = true
+		}
+		// Amazon counts ratings, the schema may call them reviews
+		count, ok := jsonNumber(rating["ratingCount"])
+		if !ok {
+			count, ok = jsonNumber(rating["reviewCount"])
+		}
+		if ok {
+			prod.Reviews = uint(count)
+			found["reviews"] = true
+		}
+	}
+	if sku, ok := obj["sku"].(string); ok && len(sku) == 10 {
+		prod.ASIN = sku
+		found["asin"] = true
+	}
+}
+
+// fromState fills the product with the price of the buy box state block
+// We have something like '{"desktop_buybox_group_1":[{"buyingOptionType":"NEW","priceAmount":12.99}, ...]}'
+// The buying options are listed in the order of the buy box so the first one with a price is the one shown
+func fromState(data map[string][]map[string]interface{}, prod *Product, found map[string]bool) {
+	for _, option := range data[buyBoxGroup] {
+		if price, ok := jsonNumber(option["priceAmount"]); ok && price > 0 && !found["price"] {
+			prod.Price = price
+			found["price"] = true
+			return
+		}
+	}
+}
+
+// fromTwister fills the product with the variation data found in the twister script
+// The script is javascript and not JSON so only the JSON parts of it are decoded
+func fromTwister(script string, prod *Product, found map[string]bool) {
+	re := regexp.MustCompile("\"parentAsin\"\\s*:\\s*\"([A-Z0-9]{10})\"")
+	if match := re.FindStringSubmatch(script); match != nil && !found["parent"] {
+		prod.Parent = match[1]
+		found["parent"] = true
+	}
+	// We have something like '"dimensionValuesDisplayData" : {"B01ABCDEFG":["Small","Red"], ...}'
+	re = regexp.MustCompile("\"dimensionValuesDisplayData\"\\s*:\\s*(\\{[^}]*\\})")
+	if match := re.FindStringSubmatch(script); match != nil {
+		var values map[string][]string
+		if err := json.Unmarshal([]byte(match[1]), &values); err == nil {
+			prod.Variations = uint(len(values))
+			found["variations"] = true
+		}
+	}
+	// We have something like '"dimensions" : ["size_name","color_name"]'
+	re = regexp.MustCompile("\"dimensions\"\\s*:\\s*(\\[[^\\]]*\\])")
+	if match := re.FindStringSubmatch(script); match != nil {
+		var dims []string
+		if err := json.Unmarshal([]byte(match[1]), &dims); err == nil {
+			for i, d := range dims {
+				dims[i] = strings.TrimSuffix(d, "_name")
+			}
+			prod.Dimensions = dims
+			found["dimensions"] = true
+		}
+	}
+}
+
+// jsonNumber converts a decoded JSON value to a number
+// Prices are often given as strings like '12.99' or '$1,299.00'
+func jsonNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case string:
+		v = strings.TrimSpace(strings.Replace(strings.TrimPrefix(v, "$"), ",", "", -1))
+		num, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, false
+		}
+		return num, true
+	}
+	return 0, false
+}
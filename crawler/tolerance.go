@@ -0,0 +1,72 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// tolerance widens the bounds of a criterion either by a percentage or by an absolute amount
+type tolerance struct {
+	value    float64
+	absolute bool
+}
+
+// toleranceCriteria holds the criteria which can have their own tolerance
+var toleranceCriteria = []string{"price", "bsr", "reviews", "rating", "size", "weight", "sellers", "variations", "sales", "revenue"}
+
+// parseTolerance converts the user input to a tolerance
+// An empty value means the criterion uses the global tolerance percentage
+func parseTolerance(value, typ string, global float64) (tolerance, error) {
+	if value == "" {
+		return tolerance{value: global}, nil
+	}
+	num, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(num) || math.IsInf(num, 0) {
+		return tolerance{}, errors.New("must be a number")
+	}
+	switch typ {
+	case "", "percent":
+		if num < 0 || num > 100 {
+			return tolerance{}, errors.New("must be between 0 and 100 percent")
+		}
+		return tolerance{value: num}, nil
+	case "absolute":
+		if num < 0 {
+			return tolerance{}, errors.New("must not be negative")
+		}
+		return tolerance{value: num, absolute: true}, nil
+	default:
+		return tolerance{}, fmt.Errorf("has an invalid type %s", typ)
+	}
+}
+
+// lower widens a lower bound downwards without going below 0
+func (t tolerance) lower(bound float64) float64 {
+	if t.absolute {
+		bound -= t.value
+	} else {
+		bound *= 1 - t.value/100
+	}
+	if bound < 0 {
+		return 0
+	}
+	return bound
+}
+
+// upper widens an upper bound upwards
+func (t tolerance) upper(bound float64) float64 {
+	if t.absolute {
+		return bound + t.value
+	}
+	return bound * (1 + t.value/100)
+}
+
+// toleranceFor returns the tolerance of the criterion falling back to the global percentage
+func (opts options) toleranceFor(criterion string) tolerance {
+	if t, ok := opts.tolerances[criterion]; ok {
+		return t
+	}
+	return tolerance{value: opts.tolerance}
+}
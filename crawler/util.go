@@ -19,7 +19,9 @@ func formatLink(link string) string {
 	link = strings.Join(s, "/")
 	u, err := url.Parse(link)
 	if err != nil {
-		log.Fatal(err)
+		// Keep the link as it is rather than stopping the crawl
+		log.Println(err)
+		return link
 	}
 	u.Scheme = "https"
 	u.Host = base
@@ -35,3 +37,16 @@ func sleep(min int, max int) {
 	delay := min + rand.Intn(max-min)
 	time.Sleep(time.Duration(delay) * time.Second)
 }
+
+// splitList splits a comma separated user input into a list of trimmed values
+// Empty values are skipped so an empty input results in an empty list
+func splitList(input string) []string {
+	var list []string
+	for _, v := range strings.Split(input, ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
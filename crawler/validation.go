@@ -0,0 +1,137 @@
+package crawler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldError describes why a search input field is invalid
+// The field is named like the form input so the frontend can highlight it
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error formats the field error in a human readable way
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s %s", e.Field, e.Message)
+}
+
+// ValidationError holds every invalid field of a search input
+type ValidationError []FieldError
+
+// Error joins all the field errors in a single message
+func (v ValidationError) Error() string {
+	msgs := make([]string, len(v))
+	for i, e := range v {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// add records an invalid field
+func (v *ValidationError) add(field, format string, args ...interface{}) {
+	*v = append(*v, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// maxGlobalTolerance is the max percentage of the global tolerance
+const maxGlobalTolerance = 10
+
+// maxRating is the max number of stars a product can be rated with
+const maxRating = 5
+
+// validateOptions checks the parsed bounds for values that make no sense
+// Negative numbers, ratings above 5 stars, inverted ranges, profit bounds without a cost,
+// a global tolerance out of range and score weights which are all zero are rejected
+func validateOptions(opts options, errs *ValidationError) {
+	bounds := []struct {
+		key string
+		b   bound
+	}{
+		{"min-price", opts.minPrice},
+		{"max-price", opts.maxPrice},
+		{"min-bsr", opts.minBSR},
+		{"max-bsr", opts.maxBSR},
+		{"min-reviews", opts.minReviews},
+		{"max-reviews", opts.maxReviews},
+		{"min-rating", opts.minRating},
+		{"max-rating", opts.maxRating},
+		{"max-length", opts.maxLength},
+		{"max-width", opts.maxWidth},
+		{"max-height", opts.maxHeight},
+		{"max-weight", opts.maxWeight},
+		{"max-sellers", opts.maxSellers},
+		{"max-variations", opts.maxVariations},
+		{"min-sales", opts.minSales},
+		{"min-revenue", opts.minRevenue},
+		{"min-profit", opts.minProfit},
+		{"min-margin", opts.minMargin},
+		{"min-roi", opts.minROI},
+	}
+	for _, in := range bounds {
+		if in.b.set && in.b.value < 0 {
+			errs.add(in.key, "must not be negative")
+		}
+	}
+
+	for _, in := range []struct {
+		key string
+		b   bound
+	}{{"min-rating", opts.minRating}, {"max-rating", opts.maxRating}} {
+		if in.b.set && in.b.value > maxRating {
+			errs.add(in.key, "must not exceed %d stars", maxRating)
+		}
+	}
+
+	ranges := []struct {
+		minKey, maxKey string
+		min, max       bound
+	}{
+		{"min-price", "max-price", opts.minPrice, opts.maxPrice},
+		{"min-bsr", "max-bsr", opts.minBSR, opts.maxBSR},
+		{"min-reviews", "max-reviews", opts.minReviews, opts.maxReviews},
+		{"min-rating", "max-rating", opts.minRating, opts.maxRating},
+	}
+	for _, r := range ranges {
+		if r.min.set && r.max.set && r.min.value >= r.max.value {
+			errs.add(r.minKey, "must be lower than %s", r.maxKey)
+			errs.add(r.maxKey, "must be greater than %s", r.minKey)
+		}
+	}
+
+	// Without a cost the profit only accounts for the fees and the ROI is unknown so their bounds would be misleading
+	if opts.cost.value <= 0 {
+		for _, in := range []struct {
+			key string
+			b   bound
+		}{{"min-profit", opts.minProfit}, {"min-margin", opts.minMargin}, {"min-roi", opts.minROI}} {
+			if in.b.set {
+				errs.add(in.key, "requires cost")
+			}
+		}
+	}
+
+	w := opts.weights
+	for _, in := range []struct {
+		key string
+		w   float64
+	}{
+		{"score-bsr", w.bsr},
+		{"score-reviews", w.reviews},
+		{"score-price", w.price},
+		{"score-size", w.size},
+		{"score-rating", w.rating},
+		{"score-competition", w.competition},
+	} {
+		if in.w < 0 {
+			errs.add(in.key, "must not be negative")
+		}
+	}
+	if w.bsr+w.reviews+w.price+w.size+w.rating+w.competition <= 0 {
+		errs.add("score-bsr", "must be positive when all the other score weights are 0")
+	}
+
+	if opts.tolerance < 0 || opts.tolerance > maxGlobalTolerance {
+		errs.add("tolerance", "must be between 0 and %d", maxGlobalTolerance)
+	}
+}
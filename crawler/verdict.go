@@ -0,0 +1,71 @@
+package crawler
+
+import "fmt"
+
+// nearMissLimit is the max number of failed criteria for a product to be a near miss
+const nearMissLimit = 1
+
+// Rejection describes a criterion the product failed with its actual and allowed values
+type Rejection struct {
+	Criterion string `json:"criterion"`
+	Actual    string `json:"actual"`
+	Allowed   string `json:"allowed"`
+}
+
+// String formats the rejection in a human readable way
+func (rej Rejection) String() string {
+	return fmt.Sprintf("%s is %s (allowed %s)", rej.Criterion, rej.Actual, rej.Allowed)
+}
+
+// Verdict tells if a product passed the filters and lists every criterion it failed
+type Verdict struct {
+	Valid      bool        `json:"valid"`
+	Rejections []Rejection `json:"rejections"`
+}
+
+// nearMiss tells if the product failed so few criteria that it may still be interesting
+func (v Verdict) nearMiss() bool {
+	return !v.Valid && len(v.Rejections) <= nearMissLimit
+}
+
+// rangeText formats the allowed range of optional bounds with the given number of decimals
+func rangeText(min, max bound, decimals int) string {
+	switch {
+	case min.set && max.set:
+		return fmt.Sprintf("%.*f - %.*f", decimals, min.value, decimals, max.value)
+	case min.set:
+		return fmt.Sprintf("at least %.*f", decimals, min.value)
+	default:
+		return fmt.Sprintf("at most %.*f", decimals, max.value)
+	}
+}
+
+// validate checks the product against the criteria of the search options
+// Unlike stopping at the first failure, all the criteria are checked so the verdict explains every rejection
+// Criteria without any bound are not checked at all
+// When the fields a criterion needs were not found the user selected policy decides what happens with the product
+func (prod *Product) validate(opts options) Verdict {
+	var v Verdict
+
+	// Start fresh since the unknown criteria are found again below
+	prod.Unknown = nil
+
+	for _, c := range opts.criteria {
+		match, known := c.filter.test(prod)
+		if known {
+			if !match {
+				v.Rejections = append(v.Rejections, Rejection{c.name, c.actual(prod), c.allowed})
+			}
+			continue
+		}
+		switch opts.strictest(c.filter.missing(prod)) {
+		case policyReject:
+			v.Rejections = append(v.Rejections, Rejection{c.name, "not found", c.allowed})
+		case policyUnknown:
+			prod.Unknown = append(prod.Unknown, c.name)
+		}
+	}
+
+	v.Valid = len(v.Rejections) == 0
+	return v
+}
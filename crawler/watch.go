@@ -0,0 +1,155 @@
+package crawler
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// asinPattern matches a valid ASIN
+var asinPattern = regexp.MustCompile("^[A-Z0-9]{10}$")
+
+// ValidASIN tells if the input is a valid ASIN
+func ValidASIN(asin string) bool {
+	return asinPattern.MatchString(asin)
+}
+
+// NormalizeASIN cleans up an ASIN typed by a user, ASINs are stored in upper case
+func NormalizeASIN(input string) string {
+	return strings.ToUpper(strings.TrimSpace(input))
+}
+
+// productLink makes the link of the product page of an ASIN
+func productLink(asin string) string {
+	return "https://" + base + "/dp/" + strings.ToUpper(asin)
+}
+
+// WatchStore keeps the watchlist and the products fetched for it
+type WatchStore interface {
+	// Watched returns the ASINs on the watchlist
+	Watched() ([]string, error)
+	// SaveWatched saves a fresh copy of a watched product
+	SaveWatched(prod Product) error
+	// LastCrawled returns the last copy of a product fetched by a crawl along with the search input of that crawl
+	// It fails when no crawl ever fetched the product
+	LastCrawled(asin string) (Product, url.Values, error)
+	// WatchFailed records that a watched product could not be fetched
+	WatchFailed(asin string, err error) error
+}
+
+// Watcher periodically fetches the products on the watchlist
+// It has its own delay between requests so it does not depend on the category crawls
+type Watcher struct {
+	Store WatchStore
+	// Interval is the time between two refreshes of the whole watchlist
+	Interval time.Duration
+	// Delay is the time between two product requests
+	Delay   time.Duration
+	Timeout time.Duration
+	// SalesCurves and FeeTable are used like in the crawls, the built-in ones are used when nil
+	SalesCurves SalesCurves
+	FeeTable    *FeeTable
+	// Refreshed is called after every refresh with the ASINs which were fetched, e.g. to evaluate alerts
+	Refreshed func(asins []string)
+	trigger   chan struct{}
+	once      sync.Once
+}
+
+// Run refreshes the watchlist right away and then every interval until stop is closed
+func (w *Watcher) Run(stop <-chan struct{}) {
+	w.init()
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		w.refresh(stop)
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		case <-w.trigger:
+		}
+	}
+}
+
+// RefreshNow asks for a refresh without waiting for the interval, e.g. after adding an ASIN
+// It does nothing when a refresh was already asked for
+func (w *Watcher) RefreshNow() {
+	w.init()
+	select {
+	case w.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// init makes the trigger channel the first time the watcher is used
+func (w *Watcher) init() {
+	w.once.Do(func() {
+		w.trigger = make(chan struct{}, 1)
+	})
+}
+
+// refresh fetches every watched product once
+func (w *Watcher) refresh(stop <-chan struct{}) {
+	asins, err := w.Store.Watched()
+	if err != nil {
+		log.Println("Error reading watchlist:", err)
+		return
+	}
+	client := &http.Client{
+		Timeout: w.Timeout * time.Second,
+	}
+	var fetched []string
+	defer func() {
+		if w.Refreshed != nil && len(fetched) > 0 {
+			w.Refreshed(fetched)
+		}
+	}()
+	for i, asin := range asins {
+		// Wait between requests except before the first one
+		if i > 0 {
+			select {
+			case <-stop:
+				return
+			case <-time.After(w.Delay):
+			}
+		}
+		p, err := getProduct(productLink(asin), client, stop)
+		if err != nil {
+			log.Println(err)
+			if err := w.Store.WatchFailed(asin, err); err != nil {
+				log.Println("Error recording watchlist failure:", err)
+			}
+			continue
+		}
+		p.ASIN = asin
+		w.estimate(&p)
+		if err := w.Store.SaveWatched(p); err != nil {
+			log.Println("Error saving watched product:", err)
+			continue
+		}
+		fetched = append(fetched, asin)
+	}
+}
+
+// estimate computes the sales, fees and score of a watched product like the last crawl which fetched it
+// so they compare with the crawl snapshots, e.g. when flagging changes or checking alert rules
+// The product page does not tell the category of the best sellers list, products never crawled use the default
+// curve and fees with no cost and the default score weights
+func (w *Watcher) estimate(p *Product) {
+	c, wts := cost{}, defaultWeights
+	if prev, values, err := w.Store.LastCrawled(p.ASIN); err == nil {
+		p.Category = prev.Category
+		// The input was valid when the crawl started so only the cost and weights are taken from it
+		if opts, err := parseOptions(values, nil); err == nil {
+			c, wts = opts.cost, opts.weights
+		}
+	}
+	slug := categorySlug(p.Category)
+	p.estimateSales(w.SalesCurves, slug)
+	p.estimateFees(w.FeeTable, slug, c)
+	p.Score = p.score(wts)
+}
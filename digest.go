@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/iulianclita/amazonsurfer/store"
+)
+
+// digestTest emails the digest of a run right away, the last finished one unless an id is given
+func digestTest(w http.ResponseWriter, r *http.Request) {
+	if digest == nil {
+		http.Error(w, "Email is disabled", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	var run store.Run
+	if id := r.FormValue("id"); id != "" {
+		var err error
+		run, err = db.Run(id)
+		if err == store.ErrNotFound {
+			http.Error(w, "Run not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	} else {
+		rs, err := db.Runs()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, rn := range rs {
+			if !rn.Finished.IsZero() {
+				run = rn
+				break
+			}
+		}
+		if run.ID == "" {
+			http.Error(w, "No finished run", http.StatusNotFound)
+			return
+		}
+	}
+	if err := digest.Send([]store.Run{run}); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, run)
+}
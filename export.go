@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/iulianclita/amazonsurfer/store"
+)
+
+// export sends the products fetched by a run as a CSV file
+// The columns are all of them unless a comma separated list is given, the numbers are formatted for the locale
+// which defaults to the browser language, and found only exports the products which passed the filters
+func export(w http.ResponseWriter, r *http.Request) {
+	if db == nil {
+		http.Error(w, "Storage is disabled", http.StatusNotFound)
+		return
+	}
+	tag := r.FormValue("locale")
+	if tag == "" {
+		// The first language the browser asks for, e.g. de-DE in 'de-DE,de;q=0.9,en;q=0.8'
+		tag = strings.TrimSpace(strings.Split(strings.Split(r.Header.Get("Accept-Language"), ",")[0], ";")[0])
+		if tag == "*" {
+			tag = ""
+		}
+	}
+	l, err := store.ParseLocale(tag)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	id := r.FormValue("run")
+	var buf bytes.Buffer
+	err = exportRun(db, &buf, id, splitColumns(r.FormValue("columns")), l, r.FormValue("found") == "true")
+	if err == store.ErrNotFound {
+		http.Error(w, "Run not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="run-`+id+`.csv"`)
+	w.Write(buf.Bytes())
+}
+
+// exportRun writes the products fetched by a run as CSV, the ones which passed the filters first then by score
+// Only the products which passed the filters are written when found is set
+func exportRun(s *store.Store, w io.Writer, id string, columns []string, l store.Locale, found bool) error {
+	snaps, err := s.RunProducts(id)
+	if err != nil {
+		return err
+	}
+	if found {
+		valid := snaps[:0]
+		for _, snap := range snaps {
+			if snap.Valid {
+				valid = append(valid, snap)
+			}
+		}
+		snaps = valid
+	}
+	sort.SliceStable(snaps, func(i, j int) bool {
+		if snaps[i].Valid != snaps[j].Valid {
+			return snaps[i].Valid
+		}
+		return snaps[i].Product.Score > snaps[j].Product.Score
+	})
+	return store.WriteCSV(w, snaps, columns, l)
+}
+
+// splitColumns reads a comma separated list of export columns, an empty list selects all of them
+func splitColumns(list string) []string {
+	var columns []string
+	for _, c := range strings.Split(list, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			columns = append(columns, c)
+		}
+	}
+	return columns
+}
+
+// envLocale returns the language of the environment locale, e.g. de_DE from LANG=de_DE.UTF-8
+func envLocale() string {
+	for _, key := range []string{"LC_ALL", "LC_NUMERIC", "LANG"} {
+		if v := os.Getenv(key); v != "" {
+			v = strings.SplitN(strings.SplitN(v, ".", 2)[0], "@", 2)[0]
+			if v == "C" || v == "POSIX" {
+				return ""
+			}
+			return v
+		}
+	}
+	return ""
+}
@@ -1,6 +1,15 @@
 module github.com/iulianclita/amazonsurfer
 
+go 1.27.1
+
 require (
 	github.com/PuerkitoBio/goquery v1.5.0
 	github.com/gorilla/websocket v1.4.0
+	go.etcd.io/bbolt v1.3.10
+)
+
+require (
+	github.com/andybalholm/cascadia v1.0.0 // indirect
+	golang.org/x/net v0.0.0-20181114220301-adae6a3d119a // indirect
+	golang.org/x/sys v0.10.0 // indirect
 )
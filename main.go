@@ -3,18 +3,23 @@ package main
 import (
 	"flag"
 	"html/template"
-	"io"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/iulianclita/amazonsurfer/alert"
 	"github.com/iulianclita/amazonsurfer/crawler"
+	"github.com/iulianclita/amazonsurfer/notify"
+	"github.com/iulianclita/amazonsurfer/schedule"
+	"github.com/iulianclita/amazonsurfer/store"
 )
 
-// Template container
-var tpl *template.Template
+// Template containers for the search page and the product detail page
+var tpl, productTpl *template.Template
 
 // Create the web crawler. It will be shared accross all calls to the server
 // This is why is mandatory to have only one opened session
@@ -22,6 +27,24 @@ var crw = &crawler.Crawler{
 	Timeout: 10,
 }
 
+// watcher refreshes the watchlist, it is nil when storage is disabled
+var watcher *crawler.Watcher
+
+// webhooks posts the events to the configured webhooks, it is nil when storage is disabled
+var webhooks *notify.Webhooks
+
+// alerts evaluates the alert rules after every crawl and watchlist refresh, it is nil when storage is disabled
+var alerts *alert.Engine
+
+// digest emails the products found by the runs, it is nil when storage or email is disabled
+var digest *notify.Digest
+
+// scheduler runs the scheduled searches, it is nil when storage is disabled
+var scheduler *schedule.Scheduler
+
+// db keeps the runs and the fetched products, it is nil when storage is disabled
+var db *store.Store
+
 // These are constants related to websockets buffer sizes
 const (
 	socketBufferSize  = 1024
@@ -35,91 +58,210 @@ var upgrader = &websocket.Upgrader{ReadBufferSize: socketBufferSize, WriteBuffer
 func init() {
 	log.SetOutput(os.Stdout)
 	tpl = template.Must(template.ParseFiles(filepath.Join("templates", "index.gohtml")))
+	productTpl = template.Must(template.ParseFiles(filepath.Join("templates", "product.gohtml")))
 }
 
-// We do not have a favicon so send 404 response
-func favicon(w http.ResponseWriter, r *http.Request) {
-	http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
-}
-
-// Index loads the home page and fills the parsed template with all the necessary data
-func index(w http.ResponseWriter, r *http.Request) {
-	data := struct {
-		Host       string
-		Categories map[uint8]string
-	}{
-		Host:       r.Host,
-		Categories: crawler.GetCategories(),
+// newCrawler makes a crawler configured like the one of the search page, e.g. for a scheduled search
+func newCrawler() *crawler.Crawler {
+	return &crawler.Crawler{
+		Timeout:       crw.Timeout,
+		DefaultFilter: crw.DefaultFilter,
+		SalesCurves:   crw.SalesCurves,
+		FeeTable:      crw.FeeTable,
+		Recorder:      crw.Recorder,
+		Hooks:         crw.Hooks,
 	}
-
-	tpl.Execute(w, data)
 }
 
-// search attaches useful data from the request to the existing web crawler
-// The crawler stores that data into its options property
-func search(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		log.Fatal("Method should be POST")
+// Main function starts the program
+// We can use a custom port if the default one is already taken
+// The default port is 1234 so in order to access out server we must visit http://localhost:1234
+func main() {
+	port := flag.String("port", "1234", "Port where the server should listen")
+	expr := flag.String("filter", "", "Default filter expression for searches without one, e.g. 'price between 10 and 30 and prime'")
+	curves := flag.String("sales-curves", "", "JSON file with the BSR to monthly sales curve of every category, e.g. sales-curves.json")
+	fees := flag.String("fees", "", "JSON file with the FBA size tiers and fees, e.g. fees.json")
+	dbFile := flag.String("db", "amazonsurfer.db", "Database file keeping the runs and the products history, empty to disable")
+	watchInterval := flag.Duration("watch-interval", 6*time.Hour, "Time between two refreshes of the watchlist")
+	watchDelay := flag.Duration("watch-delay", 30*time.Second, "Time between two product requests of the watchlist refresh")
+	hookAttempts := flag.Int("webhook-attempts", 3, "Max number of times a webhook delivery is tried")
+	hookBackoff := flag.Duration("webhook-backoff", 2*time.Second, "Wait before the first webhook retry, doubled after every retry")
+	smtpHost := flag.String("smtp-host", "", "SMTP server sending the email digests, empty to disable them")
+	smtpPort := flag.Int("smtp-port", 587, "Port of the SMTP server")
+	smtpUser := flag.String("smtp-user", "", "SMTP username, empty when the server needs no authentication")
+	smtpPassword := flag.String("smtp-password", os.Getenv("SMTP_PASSWORD"), "SMTP password, read from SMTP_PASSWORD by default")
+	smtpFrom := flag.String("smtp-from", "", "Sender address of the email digests")
+	smtpTo := flag.String("smtp-to", "", "Comma separated recipients of the email digests")
+	digestInterval := flag.Duration("digest-interval", 0, "Time between two email digests, 0 to send one after every run")
+	exportID := flag.String("export", "", "Write the products fetched by the run with this id as CSV to the standard output and exit")
+	exportColumns := flag.String("export-columns", "", "Comma separated columns of the CSV export, empty for all of them")
+	exportLocale := flag.String("export-locale", envLocale(), "Locale of the numbers in the CSV export, e.g. en-US or de-DE")
+	exportFound := flag.Bool("export-found", false, "Only export the products which passed the filters")
+	server := flag.String("server", "", "Running server the runs, diff and export commands ask when it has the database open, defaults to the one on -port")
+	flag.Parse()
+	// The export and the subcommands work on the stored runs instead of starting the server
+	if *exportID != "" || flag.NArg() > 0 {
+		var src runSource
+		if *dbFile != "" {
+			s, err := store.Open(*dbFile)
+			switch {
+			case err == store.ErrLocked:
+				// The server has the database open so it is asked instead
+				if *server == "" {
+					*server = *port
+				}
+				src = newServerSource(*server)
+			case err != nil:
+				log.Fatal("Cannot open database: ", err)
+			default:
+				src = localSource{s}
+			}
+		}
+		code := 0
+		if *exportID != "" {
+			code = exportCommand(src, *exportID, *exportColumns, *exportLocale, *exportFound)
+		} else {
+			code = command(src, flag.Args())
+		}
+		// The deferred calls do not run with os.Exit
+		if s, ok := src.(localSource); ok {
+			s.Close()
+		}
+		os.Exit(code)
 	}
-	if err := r.ParseForm(); err != nil {
-		log.Fatal("Error parsing the form")
+	if *dbFile != "" {
+		s, err := store.Open(*dbFile)
+		if err != nil {
+			log.Fatal("Cannot open database: ", err)
+		}
+		defer s.Close()
+		db = s
+		crw.Recorder = s
 	}
-	err := crw.MapOptions(r)
-	if err != nil {
-		io.WriteString(w, err.Error())
-		log.Fatal(err)
-		return
+	if *curves != "" {
+		c, err := crawler.LoadSalesCurves(*curves)
+		if err != nil {
+			log.Fatal("Invalid sales curves: ", err)
+		}
+		crw.SalesCurves = c
 	}
-	io.WriteString(w, "ok")
-}
-
-// Here is the core processing where the lookup is made
-// We start by upgrading our connection to websockets
-// After we launch the crawler in the background to search for products
-// We wait for products to be sent in the main goroutine and flush them in the frontend
-func start(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
-	defer conn.Close()
-	if err != nil {
-		log.Fatal("Socker error:", err)
+	if *fees != "" {
+		t, err := crawler.LoadFeeTable(*fees)
+		if err != nil {
+			log.Fatal("Invalid fee table: ", err)
+		}
+		crw.FeeTable = &t
 	}
-	// This channel will receive the products from the crawler
-	prods := make(chan crawler.Product)
-	// Run the crawler in the background
-	go crw.Run(conn, prods)
-	// Wait for incoming products
-	for p := range prods {
-		// Check for exit signal before sending the product
-		select {
-		case <-crw.Done:
-			return
-		default:
-			if err := conn.WriteJSON(p); err != nil {
-				log.Println("Send error:", err)
-			}
+	if *expr != "" {
+		f, err := crawler.ParseFilter(*expr)
+		if err != nil {
+			log.Fatal("Invalid filter: ", err)
 		}
+		crw.DefaultFilter = f
 	}
-}
-
-// stop closses the current websockets connection
-func stop(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		log.Fatal("Method should be POST")
+	if db != nil {
+		if *hookAttempts < 1 {
+			log.Fatal("Invalid webhook attempts: ", *hookAttempts)
+		}
+		webhooks = &notify.Webhooks{
+			Store:    db,
+			Client:   &http.Client{Timeout: 10 * time.Second},
+			Attempts: *hookAttempts,
+			Backoff:  *hookBackoff,
+		}
+		alerts = &alert.Engine{Store: db, Notifier: notify.Notifiers{notify.Log{}, webhooks}}
+		if *smtpHost != "" {
+			var to []string
+			for _, addr := range strings.Split(*smtpTo, ",") {
+				if addr = strings.TrimSpace(addr); addr != "" {
+					to = append(to, addr)
+				}
+			}
+			if *smtpFrom == "" || len(to) == 0 {
+				log.Fatal("Email digests need a sender and at least one recipient")
+			}
+			if *digestInterval < 0 {
+				log.Fatal("Invalid digest interval: ", *digestInterval)
+			}
+			digest = &notify.Digest{
+				Store: db,
+				Mailer: &notify.Mailer{
+					Host:     *smtpHost,
+					Port:     *smtpPort,
+					Username: *smtpUser,
+					Password: *smtpPassword,
+					From:     *smtpFrom,
+					To:       to,
+				},
+				Interval: *digestInterval,
+			}
+			if *digestInterval > 0 {
+				go digest.Run(nil)
+			}
+		}
+		crw.Hooks = crawler.Hooks{
+			Found: func(run string, prod crawler.Product) {
+				webhooks.Notify(notify.NewEvent(notify.EventProduct, notify.ProductFound{Run: run, Product: prod}))
+			},
+			Finished: func(run string, rep crawler.Report, err error) {
+				e := notify.NewEvent(notify.EventRunFinished, notify.RunEnded{Run: run, Report: rep})
+				if err != nil {
+					e = notify.NewEvent(notify.EventRunFailed, notify.RunEnded{Run: run, Report: rep, Error: err.Error()})
+				}
+				webhooks.Notify(e)
+				if digest != nil {
+					go func() {
+						if err := digest.Notify(e); err != nil {
+							log.Println("Error sending digest:", err)
+						}
+					}()
+				}
+				// Check the alert rules against the products the crawl fetched
+				go alerts.EvaluateRun(run)
+			},
+		}
+		if *watchInterval <= 0 {
+			log.Fatal("Invalid watch interval: ", *watchInterval)
+		}
+		watcher = &crawler.Watcher{
+			Store:       db,
+			Interval:    *watchInterval,
+			Delay:       *watchDelay,
+			Timeout:     crw.Timeout,
+			SalesCurves: crw.SalesCurves,
+			FeeTable:    crw.FeeTable,
+			Refreshed: func(asins []string) {
+				alerts.Evaluate(store.WatchRun, asins)
+			},
+		}
+		go watcher.Run(nil)
+		scheduler = &schedule.Scheduler{Store: db, NewCrawler: newCrawler}
+		go scheduler.Run(nil)
 	}
-	crw.Stop()
-}
-
-// Main function starts the program
-// We can use a custom port if the default one is already taken
-// The default port is 1234 so in order to access out server we must visit http://localhost:1234
-func main() {
-	port := flag.String("port", "1234", "Port where the server should listen")
-	flag.Parse()
 	http.Handle("/assets/", http.StripPrefix("/assets/", http.FileServer(http.Dir("./assets"))))
 	http.HandleFunc("/favicon.ico", favicon)
 	http.HandleFunc("/search", search)
 	http.HandleFunc("/start", start)
 	http.HandleFunc("/stop", stop)
+	http.HandleFunc("/report", report)
+	http.HandleFunc("/ranking", ranking)
+	http.HandleFunc("/runs", runs)
+	http.HandleFunc("/product", product)
+	http.HandleFunc("/history", history)
+	http.HandleFunc("/diff", diff)
+	http.HandleFunc("/watchlist", watchlist)
+	http.HandleFunc("/rules", rules)
+	http.HandleFunc("/alerts", alertsList)
+	http.HandleFunc("/webhooks", webhooksList)
+	http.HandleFunc("/webhooks/test", webhookTest)
+	http.HandleFunc("/deliveries", deliveries)
+	http.HandleFunc("/digest/test", digestTest)
+	http.HandleFunc("/presets", presets)
+	http.HandleFunc("/export", export)
+	http.HandleFunc("/schedules", schedules)
+	http.HandleFunc("/schedules/pause", schedulePause)
+	http.HandleFunc("/schedules/run", scheduleRun)
+	http.HandleFunc("/filter", filter)
 	http.HandleFunc("/", index)
 	log.Printf("Listening on port %s\n", *port)
 	addr := ":" + *port
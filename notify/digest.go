@@ -0,0 +1,151 @@
+package notify
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/iulianclita/amazonsurfer/crawler"
+	"github.com/iulianclita/amazonsurfer/store"
+)
+
+// DigestStore keeps the runs and the products the digests are made of
+type DigestStore interface {
+	Runs() ([]store.Run, error)
+	Run(id string) (store.Run, error)
+	RunProducts(id string) ([]store.Snapshot, error)
+}
+
+// digest is the data the digest templates are executed with
+type digest struct {
+	Runs     []store.Run
+	Products []crawler.Product
+}
+
+// digestText is the plain text body of a digest
+var digestText = template.Must(template.New("text").Parse(`{{len .Products}} products found by {{len .Runs}} run(s)
+{{range .Runs}}
+Run {{.ID}} started {{.Started.Local.Format "2006-01-02 15:04"}}: {{.Report.Products}} products parsed, {{.Report.Found}} found{{end}}
+
+{{printf "%-10s  %-50.50s  %9s  %8s  %7s  %6s  %6s  %8s  %6s" "ASIN" "Name" "Price" "BSR" "Reviews" "Rating" "Sales" "Profit" "Score"}}
+{{range .Products}}{{printf "%-10s  %-50.50s  %9.2f  %8d  %7d  %6.1f  %6d  %8.2f  %6.1f" .ASIN .Name .Price .BSR .Reviews .Rating .Sales .Profit .Score}}
+{{end}}`))
+
+// digestHTML is the HTML body of a digest
+var digestHTML = htmltemplate.Must(htmltemplate.New("html").Parse(`<html><body>
+<p><strong>{{len .Products}} products found by {{len .Runs}} run(s)</strong></p>
+<ul>{{range .Runs}}<li>Run {{.ID}} started {{.Started.Local.Format "2006-01-02 15:04"}}: {{.Report.Products}} products parsed, {{.Report.Found}} found</li>{{end}}</ul>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>Product</th><th>Price</th><th>BSR</th><th>Reviews</th><th>Rating</th><th>Sales / month</th><th>Profit</th><th>Score</th></tr>
+{{range .Products}}<tr><td><a href="{{.Link}}">{{if .Name}}{{.Name}}{{else}}{{.ASIN}}{{end}}</a></td><td>{{printf "%.2f" .Price}}</td><td>{{.BSR}}</td><td>{{.Reviews}}</td><td>{{printf "%.1f" .Rating}}</td><td>{{.Sales}}</td><td>{{printf "%.2f" .Profit}}</td><td>{{printf "%.1f" .Score}}</td></tr>
+{{end}}</table>
+</body></html>`))
+
+// Digest is a notifier emailing the products found by the runs
+// With no interval a digest is sent when a run finishes, otherwise the runs finished since the last digest
+// are gathered and sent every interval by Run
+type Digest struct {
+	Store    DigestStore
+	Mailer   *Mailer
+	Interval time.Duration
+	// since is the end of the period covered by the last scheduled digest
+	since time.Time
+	mu    sync.Mutex
+}
+
+// Notify sends the digest of a finished run when there is no schedule
+func (d *Digest) Notify(e Event) error {
+	if d.Interval > 0 || e.Type != EventRunFinished {
+		return nil
+	}
+	ended, ok := e.Data.(RunEnded)
+	if !ok {
+		return nil
+	}
+	run, err := d.Store.Run(ended.Run)
+	if err != nil {
+		return err
+	}
+	return d.Send([]store.Run{run})
+}
+
+// Run sends the scheduled digests every interval until stop is closed
+func (d *Digest) Run(stop <-chan struct{}) {
+	d.mu.Lock()
+	d.since = time.Now().UTC()
+	d.mu.Unlock()
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			if err := d.sendSince(now.UTC()); err != nil {
+				log.Println("Error sending digest:", err)
+			}
+		}
+	}
+}
+
+// sendSince sends the digest of the runs finished since the last one, nothing is sent when there are none
+func (d *Digest) sendSince(now time.Time) error {
+	d.mu.Lock()
+	since := d.since
+	d.since = now
+	d.mu.Unlock()
+	all, err := d.Store.Runs()
+	if err != nil {
+		return err
+	}
+	var runs []store.Run
+	for _, run := range all {
+		if !run.Finished.IsZero() && run.Finished.After(since) && !run.Finished.After(now) {
+			runs = append(runs, run)
+		}
+	}
+	if len(runs) == 0 {
+		return nil
+	}
+	return d.Send(runs)
+}
+
+// Send emails the digest of the products which passed the filters of the runs, best scores first
+func (d *Digest) Send(runs []store.Run) error {
+	data := digest{Runs: runs}
+	for _, run := range runs {
+		snaps, err := d.Store.RunProducts(run.ID)
+		if err != nil {
+			return err
+		}
+		for _, snap := range snaps {
+			if snap.Valid {
+				data.Products = append(data.Products, snap.Product)
+			}
+		}
+	}
+	sort.SliceStable(data.Products, func(i, j int) bool { return data.Products[i].Score > data.Products[j].Score })
+
+	var text, html bytes.Buffer
+	if err := digestText.Execute(&text, data); err != nil {
+		return err
+	}
+	if err := digestHTML.Execute(&html, data); err != nil {
+		return err
+	}
+	subject := "Amazon Surfer: " + plural(len(data.Products), "product") + " found"
+	return d.Mailer.Send(subject, text.String(), html.String())
+}
+
+// plural formats a count with its noun
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return strconv.Itoa(n) + " " + noun + "s"
+}
@@ -0,0 +1,305 @@
+package notify
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iulianclita/amazonsurfer/crawler"
+	"github.com/iulianclita/amazonsurfer/store"
+)
+
+// smtpMessage is an email received by the SMTP stub
+type smtpMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+// smtpStub is a local SMTP server keeping the emails it receives
+type smtpStub struct {
+	ln       net.Listener
+	mu       sync.Mutex
+	messages []smtpMessage
+	wg       sync.WaitGroup
+}
+
+// newSMTPStub starts an SMTP stub listening on a free local port
+func newSMTPStub(t *testing.T) *smtpStub {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub := &smtpStub{ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			stub.wg.Add(1)
+			go stub.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return stub
+}
+
+// mailer returns a mailer sending through the stub
+func (stub *smtpStub) mailer() *Mailer {
+	addr := stub.ln.Addr().(*net.TCPAddr)
+	return &Mailer{Host: addr.IP.String(), Port: addr.Port, From: "surfer@example.com", To: []string{"me@example.com", "you@example.com"}}
+}
+
+// received returns the emails received so far once the open sessions end
+func (stub *smtpStub) received() []smtpMessage {
+	stub.wg.Wait()
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	return stub.messages
+}
+
+// serve answers a single SMTP session, only what net/smtp needs to send an email
+func (stub *smtpStub) serve(conn net.Conn) {
+	defer stub.wg.Done()
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+	reply("220 stub ESMTP")
+	var msg smtpMessage
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 stub")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			msg = smtpMessage{from: strings.Trim(line[len("MAIL FROM:"):], "<>")}
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			msg.to = append(msg.to, strings.Trim(line[len("RCPT TO:"):], "<>"))
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			msg.data = data.String()
+			stub.mu.Lock()
+			stub.messages = append(stub.messages, msg)
+			stub.mu.Unlock()
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+// parseDigest returns the subject and the plain text and HTML bodies of a digest email
+func parseDigest(t *testing.T, data string) (string, string, string) {
+	msg, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bodies := make(map[string]string)
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		typ, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		body, _ := io.ReadAll(p)
+		bodies[typ] = string(body)
+	}
+	return subject, bodies["text/plain"], bodies["text/html"]
+}
+
+// digestStore is an in memory store of runs and their products
+type digestStore struct {
+	runs     []store.Run
+	products map[string][]store.Snapshot
+}
+
+func (ds *digestStore) Runs() ([]store.Run, error) {
+	return ds.runs, nil
+}
+
+func (ds *digestStore) Run(id string) (store.Run, error) {
+	for _, run := range ds.runs {
+		if run.ID == id {
+			return run, nil
+		}
+	}
+	return store.Run{}, store.ErrNotFound
+}
+
+func (ds *digestStore) RunProducts(id string) ([]store.Snapshot, error) {
+	return ds.products[id], nil
+}
+
+// newDigestStore makes a store of runs finished at the given times, each with a valid and an invalid product
+func newDigestStore(finished ...time.Time) *digestStore {
+	ds := &digestStore{products: make(map[string][]store.Snapshot)}
+	for i, end := range finished {
+		id := "r" + strconv.Itoa(i+1)
+		ds.runs = append(ds.runs, store.Run{ID: id, Started: end.Add(-time.Minute), Finished: end, Report: crawler.Report{Products: 2, Found: 1}})
+		ds.products[id] = []store.Snapshot{
+			{Run: id, Valid: true, Product: crawler.Product{ASIN: "B0000000" + strconv.Itoa(10+i), Name: "Found <" + id + ">", Link: "https://www.amazon.com/dp/" + id, Score: float64(10 * (i + 1))}},
+			{Run: id, Valid: false, Product: crawler.Product{ASIN: "B0000000" + strconv.Itoa(50+i), Name: "Rejected " + id}},
+		}
+	}
+	return ds
+}
+
+func TestDigestSend(t *testing.T) {
+	stub := newSMTPStub(t)
+	now := time.Now().UTC()
+	ds := newDigestStore(now.Add(-2*time.Hour), now.Add(-time.Hour))
+	d := &Digest{Store: ds, Mailer: stub.mailer()}
+
+	if err := d.Send(ds.runs); err != nil {
+		t.Fatal(err)
+	}
+	msgs := stub.received()
+	if len(msgs) != 1 {
+		t.Fatalf("got %d emails, want 1", len(msgs))
+	}
+	if msgs[0].from != "surfer@example.com" || strings.Join(msgs[0].to, ",") != "me@example.com,you@example.com" {
+		t.Errorf("email sent from %s to %v", msgs[0].from, msgs[0].to)
+	}
+	subject, text, html := parseDigest(t, msgs[0].data)
+	if subject != "Amazon Surfer: 2 products found" {
+		t.Errorf("got subject %q", subject)
+	}
+	for _, want := range []string{"2 products found by 2 run(s)", "Run r1 started", "Run r2 started", "2 products parsed, 1 found"} {
+		if !strings.Contains(text, want) || !strings.Contains(html, want) {
+			t.Errorf("digest does not tell %q:\n%s\n%s", want, text, html)
+		}
+	}
+	// Only the products which passed the filters are listed, best scores first
+	if strings.Contains(text, "Rejected") || strings.Contains(html, "Rejected") {
+		t.Errorf("digest lists rejected products:\n%s", text)
+	}
+	if first, second := strings.Index(text, "Found <r2>"), strings.Index(text, "Found <r1>"); first < 0 || second < 0 || first > second {
+		t.Errorf("digest does not list the best score first:\n%s", text)
+	}
+	// Scraped names are escaped in the HTML body
+	if !strings.Contains(html, `<a href="https://www.amazon.com/dp/r2">Found &lt;r2&gt;</a>`) {
+		t.Errorf("digest HTML does not link the escaped name:\n%s", html)
+	}
+}
+
+func TestDigestNotify(t *testing.T) {
+	stub := newSMTPStub(t)
+	ds := newDigestStore(time.Now().UTC())
+	d := &Digest{Store: ds, Mailer: stub.mailer()}
+
+	// Only finished runs send a digest
+	for _, e := range []Event{
+		NewEvent(EventRunFailed, RunEnded{Run: "r1"}),
+		NewEvent(EventProduct, ProductFound{Run: "r1"}),
+		NewEvent(EventRunFinished, RunEnded{Run: "r1"}),
+	} {
+		if err := d.Notify(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	msgs := stub.received()
+	if len(msgs) != 1 {
+		t.Fatalf("got %d emails, want 1", len(msgs))
+	}
+	if subject, _, _ := parseDigest(t, msgs[0].data); subject != "Amazon Surfer: 1 product found" {
+		t.Errorf("got subject %q", subject)
+	}
+
+	// A scheduled digest waits for its interval instead
+	d.Interval = time.Hour
+	if err := d.Notify(NewEvent(EventRunFinished, RunEnded{Run: "r1"})); err != nil {
+		t.Fatal(err)
+	}
+	if msgs := stub.received(); len(msgs) != 1 {
+		t.Errorf("got %d emails, want no more", len(msgs))
+	}
+}
+
+func TestDigestBatching(t *testing.T) {
+	stub := newSMTPStub(t)
+	start := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	// r1 finished before the period, r2 and r3 inside it and r4 after it, r5 is still running
+	ds := newDigestStore(start.Add(-time.Minute), start.Add(10*time.Minute), start.Add(time.Hour), start.Add(2*time.Hour))
+	ds.runs = append(ds.runs, store.Run{ID: "r5", Started: start})
+	d := &Digest{Store: ds, Mailer: stub.mailer(), Interval: time.Hour, since: start}
+
+	if err := d.sendSince(start.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	// The next period has no finished run so nothing is sent
+	if err := d.sendSince(start.Add(90 * time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	msgs := stub.received()
+	if len(msgs) != 1 {
+		t.Fatalf("got %d emails, want 1", len(msgs))
+	}
+	subject, text, _ := parseDigest(t, msgs[0].data)
+	if subject != "Amazon Surfer: 2 products found" {
+		t.Errorf("got subject %q", subject)
+	}
+	for _, run := range []string{"r1", "r4", "r5"} {
+		if strings.Contains(text, "Run "+run+" ") {
+			t.Errorf("digest holds run %s out of its period:\n%s", run, text)
+		}
+	}
+	for _, run := range []string{"r2", "r3"} {
+		if !strings.Contains(text, "Run "+run+" ") {
+			t.Errorf("digest misses run %s:\n%s", run, text)
+		}
+	}
+
+	// The following period picks up where the last one ended
+	if err := d.sendSince(start.Add(3 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	msgs = stub.received()
+	if len(msgs) != 2 {
+		t.Fatalf("got %d emails, want 2", len(msgs))
+	}
+	if _, text, _ := parseDigest(t, msgs[1].data); !strings.Contains(text, "Run r4 ") || strings.Contains(text, "Run r3 ") {
+		t.Errorf("second digest does not hold only run r4:\n%s", text)
+	}
+}
@@ -0,0 +1,73 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mailer sends emails through an SMTP server
+// Credentials are optional, without them the server must accept mail without authentication
+type Mailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// Send sends an email with both a plain text and an HTML body
+// Mail clients show the HTML one and fall back to the plain text one
+func (m *Mailer) Send(subject, text, html string) error {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	headers := []string{
+		"From: " + m.From,
+		"To: " + strings.Join(m.To, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + mw.Boundary(),
+	}
+	parts := []struct {
+		typ  string
+		body string
+	}{
+		{"text/plain", text},
+		{"text/html", html},
+	}
+	for _, p := range parts {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.typ + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"8bit"},
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write([]byte(p.body)); err != nil {
+			return err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+	msg := []byte(strings.Join(headers, "\r\n") + "\r\n\r\n" + body.String())
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	if err := smtp.SendMail(addr, auth, m.From, m.To, msg); err != nil {
+		return fmt.Errorf("sending email through %s: %s", addr, err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+// Package notify delivers the events of the application (alerts, finished runs) to the outside world
+// Every way of delivering them is a Notifier so new ones can be plugged in
+package notify
+
+import (
+	"log"
+	"time"
+
+	"github.com/iulianclita/amazonsurfer/crawler"
+)
+
+// These are the types of events
+const (
+	// EventProduct is raised for every product which passed the filters of a crawl
+	EventProduct = "product.found"
+	// EventRunFinished and EventRunFailed are raised at the end of a crawl, the data is the run and its report
+	EventRunFinished = "run.finished"
+	EventRunFailed   = "run.failed"
+	// EventAlert is raised by an alert rule, the data is the alert
+	EventAlert = "alert"
+	// EventPing is sent to test a webhook
+	EventPing = "ping"
+)
+
+// EventTypes holds all the event types a webhook can ask for
+var EventTypes = []string{EventProduct, EventRunFinished, EventRunFailed, EventAlert, EventPing}
+
+// Event is something that happened which the notifiers are told about
+type Event struct {
+	Type string      `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// ProductFound is the data of a product found event
+type ProductFound struct {
+	Run     string          `json:"run"`
+	Product crawler.Product `json:"product"`
+}
+
+// RunEnded is the data of a run finished or failed event
+type RunEnded struct {
+	Run    string         `json:"run"`
+	Report crawler.Report `json:"report"`
+	Error  string         `json:"error,omitempty"`
+}
+
+// NewEvent makes an event happening now
+func NewEvent(typ string, data interface{}) Event {
+	return Event{Type: typ, Time: time.Now().UTC(), Data: data}
+}
+
+// Notifier delivers events
+type Notifier interface {
+	Notify(e Event) error
+}
+
+// Notifiers delivers the events to all the notifiers it holds
+// A failing notifier does not stop the others, its error is logged
+type Notifiers []Notifier
+
+// Notify delivers the event to every notifier
+func (ns Notifiers) Notify(e Event) error {
+	for _, n := range ns {
+		if err := n.Notify(e); err != nil {
+			log.Printf("Error delivering %s event: %s\n", e.Type, err)
+		}
+	}
+	return nil
+}
+
+// Log is a notifier which writes the events to the application log
+type Log struct{}
+
+// Notify logs the event
+func (Log) Notify(e Event) error {
+	log.Printf("Event %s: %+v\n", e.Type, e.Data)
+	return nil
+}
@@ -0,0 +1,122 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/iulianclita/amazonsurfer/store"
+)
+
+// These are the headers sent along with every webhook payload
+const (
+	// HeaderEvent holds the event type
+	HeaderEvent = "X-Amazonsurfer-Event"
+	// HeaderSignature holds 'sha256=' followed by the hex HMAC SHA256 of the body keyed with the webhook secret
+	HeaderSignature = "X-Amazonsurfer-Signature"
+)
+
+// WebhookStore keeps the webhooks and the log of their deliveries
+type WebhookStore interface {
+	Webhooks() ([]store.Webhook, error)
+	SaveDelivery(d store.Delivery) (store.Delivery, error)
+}
+
+// Webhooks is a notifier posting the events as JSON to the webhooks which want them
+// Failed deliveries are retried with a doubling backoff and every delivery is logged
+type Webhooks struct {
+	Store WebhookStore
+	// Client sends the requests, its timeout bounds every attempt
+	Client *http.Client
+	// Attempts is the max number of times a delivery is tried
+	Attempts int
+	// Backoff is the wait before the first retry, it doubles after every retry
+	Backoff time.Duration
+}
+
+// Notify posts the event to the enabled webhooks which want it
+// Deliveries run in the background so the caller is never held by a slow receiver
+func (wh *Webhooks) Notify(e Event) error {
+	hooks, err := wh.Store.Webhooks()
+	if err != nil {
+		return err
+	}
+	for _, h := range hooks {
+		if h.Enabled && h.Wants(e.Type) {
+			go wh.Deliver(h, e)
+		}
+	}
+	return nil
+}
+
+// Deliver posts the event to the webhook retrying until it is accepted or the attempts run out
+// A 2xx status means the event was accepted
+func (wh *Webhooks) Deliver(h store.Webhook, e Event) store.Delivery {
+	d := store.Delivery{Webhook: h.ID, Event: e.Type, Time: time.Now().UTC()}
+	body, err := json.Marshal(e)
+	if err != nil {
+		d.Error = err.Error()
+		return wh.log(d)
+	}
+	backoff := wh.Backoff
+	for d.Attempts < wh.Attempts {
+		if d.Attempts > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		d.Attempts++
+		d.Status, err = wh.post(h, e.Type, body)
+		if err == nil {
+			d.Delivered = true
+			d.Error = ""
+			break
+		}
+		d.Error = err.Error()
+	}
+	return wh.log(d)
+}
+
+// post sends a single delivery attempt and returns the status received
+func (wh *Webhooks) post(h store.Webhook, typ string, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, typ)
+	if h.Secret != "" {
+		req.Header.Set(HeaderSignature, "sha256="+Sign(h.Secret, body))
+	}
+	res, err := wh.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("webhook answered with status %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+// log saves the delivery in the delivery log
+func (wh *Webhooks) log(d store.Delivery) store.Delivery {
+	d, err := wh.Store.SaveDelivery(d)
+	if err != nil {
+		log.Println("Error saving webhook delivery:", err)
+	}
+	return d
+}
+
+// Sign computes the hex HMAC SHA256 of a payload keyed with the secret
+// Receivers compute it on the body they got and compare it with the signature header
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
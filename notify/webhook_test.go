@@ -0,0 +1,139 @@
+package notify
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iulianclita/amazonsurfer/store"
+)
+
+// receiver is a local webhook receiver answering with the given statuses in turn, the last one repeats
+type receiver struct {
+	statuses []int
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.requests = append(rc.requests, r)
+	rc.bodies = append(rc.bodies, body)
+	status := rc.statuses[len(rc.statuses)-1]
+	if n := len(rc.requests) - 1; n < len(rc.statuses) {
+		status = rc.statuses[n]
+	}
+	w.WriteHeader(status)
+}
+
+// openStore opens an empty store in a temporary directory
+func openStore(t *testing.T) *store.Store {
+	s, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestWebhookDeliver(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		statuses  []int
+		attempts  int
+		delivered bool
+		status    int
+		err       string
+	}{
+		{"accepted", "s3cret", []int{http.StatusOK}, 3, true, http.StatusOK, ""},
+		{"accepted without secret", "", []int{http.StatusNoContent}, 3, true, http.StatusNoContent, ""},
+		{"retried", "s3cret", []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusAccepted}, 3, true, http.StatusAccepted, ""},
+		{"attempts run out", "s3cret", []int{http.StatusServiceUnavailable}, 2, false, http.StatusServiceUnavailable, "webhook answered with status 503"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := &receiver{statuses: tt.statuses}
+			srv := httptest.NewServer(rc)
+			defer srv.Close()
+			s := openStore(t)
+			h, err := s.SaveWebhook(store.Webhook{URL: srv.URL, Secret: tt.secret, Enabled: true}, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wh := &Webhooks{Store: s, Client: srv.Client(), Attempts: tt.attempts, Backoff: time.Millisecond}
+			e := NewEvent(EventRunFinished, RunEnded{Run: "r1"})
+
+			d := wh.Deliver(h, e)
+			wantAttempts := tt.attempts
+			if tt.delivered {
+				wantAttempts = len(tt.statuses)
+			}
+			if d.Delivered != tt.delivered || d.Attempts != wantAttempts || d.Status != tt.status || d.Error != tt.err {
+				t.Errorf("got delivery %+v, want delivered %v after %d attempts with status %d and error %q",
+					d, tt.delivered, wantAttempts, tt.status, tt.err)
+			}
+
+			// Every attempt posts the same signed payload
+			if len(rc.requests) != wantAttempts {
+				t.Fatalf("receiver got %d requests, want %d", len(rc.requests), wantAttempts)
+			}
+			for i, r := range rc.requests {
+				if got := r.Header.Get("Content-Type"); got != "application/json" {
+					t.Errorf("attempt %d has content type %q", i+1, got)
+				}
+				if got := r.Header.Get(HeaderEvent); got != EventRunFinished {
+					t.Errorf("attempt %d has event header %q, want %q", i+1, got, EventRunFinished)
+				}
+				sig := r.Header.Get(HeaderSignature)
+				switch {
+				case tt.secret == "" && sig != "":
+					t.Errorf("attempt %d is signed without a secret: %q", i+1, sig)
+				case tt.secret != "" && sig != "sha256="+Sign(tt.secret, rc.bodies[i]):
+					t.Errorf("attempt %d has signature %q which does not match its body", i+1, sig)
+				}
+				var payload struct {
+					Type string    `json:"type"`
+					Time time.Time `json:"time"`
+					Data RunEnded  `json:"data"`
+				}
+				if err := json.Unmarshal(rc.bodies[i], &payload); err != nil {
+					t.Fatalf("attempt %d has an invalid payload: %v", i+1, err)
+				}
+				if payload.Type != EventRunFinished || !payload.Time.Equal(e.Time) || payload.Data.Run != "r1" {
+					t.Errorf("attempt %d has payload %+v", i+1, payload)
+				}
+			}
+
+			// The delivery is logged with its outcome
+			logged, err := s.Deliveries(10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(logged) != 1 {
+				t.Fatalf("got %d logged deliveries, want 1", len(logged))
+			}
+			if got := logged[0]; got.ID != d.ID || got.Webhook != h.ID || got.Event != EventRunFinished ||
+				got.Delivered != tt.delivered || got.Attempts != wantAttempts || got.Status != tt.status || got.Error != tt.err {
+				t.Errorf("got logged delivery %+v, want %+v", got, d)
+			}
+		})
+	}
+}
+
+func TestSign(t *testing.T) {
+	// The signature of the RFC 4231 test case 2
+	got := Sign("Jefe", []byte("what do ya want for nothing?"))
+	want := "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"
+	if got != want {
+		t.Errorf("got signature %s, want %s", got, want)
+	}
+}
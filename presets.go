@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/iulianclita/amazonsurfer/crawler"
+	"github.com/iulianclita/amazonsurfer/store"
+)
+
+// presets lists the search presets on GET, or sends one when an id is given
+// It saves a preset on POST and removes one on DELETE
+// A preset is posted like a search with the preset-name field added, along with preset-id to update an existing one
+func presets(w http.ResponseWriter, r *http.Request) {
+	if db == nil {
+		http.Error(w, "Storage is disabled", http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		if id := r.FormValue("id"); id != "" {
+			p, err := db.Preset(id)
+			if err == store.ErrNotFound {
+				http.Error(w, "Preset not found", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, http.StatusOK, p)
+			return
+		}
+		ps, err := db.Presets()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, ps)
+	case http.MethodPost:
+		values, err := crawler.RequestValues(r)
+		if err != nil {
+			writeErrors(w, err)
+			return
+		}
+		p := store.Preset{
+			ID:      values.Get("preset-id"),
+			Name:    strings.TrimSpace(values.Get("preset-name")),
+			Options: searchValues(values, presetFields),
+		}
+		if err := validatePreset(p); err != nil {
+			writeErrors(w, err)
+			return
+		}
+		p, err = db.SavePreset(p)
+		if err == store.ErrNotFound {
+			http.Error(w, "Preset not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, p)
+	case http.MethodDelete:
+		err := db.DeletePreset(r.FormValue("id"))
+		if err == store.ErrNotFound {
+			http.Error(w, "Preset not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, "ok")
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// presetFields are the inputs describing the preset itself, all the others are search options
+var presetFields = []string{"preset-id", "preset-name"}
+
+// validatePreset checks the name and the search options of a preset and returns the invalid fields
+// Only valid search input is saved so every preset can start a crawl
+func validatePreset(p store.Preset) error {
+	var errs crawler.ValidationError
+	if p.Name == "" {
+		errs = append(errs, crawler.FieldError{Field: "preset-name", Message: "must not be empty"})
+	}
+	if err := newCrawler().SetOptions(p.Options); err != nil {
+		if verrs, ok := err.(crawler.ValidationError); ok {
+			errs = append(errs, verrs...)
+		} else {
+			errs = append(errs, crawler.FieldError{Field: "options", Message: err.Error()})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
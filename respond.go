@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/iulianclita/amazonsurfer/crawler"
+)
+
+// parseLimit reads the optional limit of a listing, 100 by default
+// It answers with an error and returns false when the limit is invalid
+func parseLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	limit := 100
+	if v := r.FormValue("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return 0, false
+		}
+		limit = l
+	}
+	return limit, true
+}
+
+// writeErrors answers with the invalid fields as JSON so the frontend can highlight them
+func writeErrors(w http.ResponseWriter, err error) {
+	errs, ok := err.(crawler.ValidationError)
+	if !ok {
+		errs = crawler.ValidationError{{Message: err.Error()}}
+	}
+	writeJSON(w, http.StatusBadRequest, struct {
+		Errors crawler.ValidationError `json:"errors"`
+	}{errs})
+}
+
+// writeJSON sends the data as a JSON response with the given status
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		log.Println("Encode error:", err)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/iulianclita/amazonsurfer/store"
+)
+
+// runs lists the recorded crawls with the most recent first
+// With an id it sends that run along with the snapshots of the products it fetched
+func runs(w http.ResponseWriter, r *http.Request) {
+	if db == nil {
+		http.Error(w, "Storage is disabled", http.StatusNotFound)
+		return
+	}
+	id := r.FormValue("id")
+	if id == "" {
+		rs, err := db.Runs()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, rs)
+		return
+	}
+	run, err := db.Run(id)
+	if err == store.ErrNotFound {
+		http.Error(w, "Run not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	prods, err := db.RunProducts(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		store.Run
+		Products []store.Snapshot `json:"products"`
+	}{run, prods})
+}
+
+// product loads the detail page of a product which charts its history
+func product(w http.ResponseWriter, r *http.Request) {
+	asin := r.FormValue("asin")
+	if asin == "" {
+		http.Error(w, "Missing asin", http.StatusBadRequest)
+		return
+	}
+	productTpl.Execute(w, struct{ ASIN string }{asin})
+}
+
+// history sends the price, BSR, reviews and rating of a product over every crawl that fetched it
+// along with their 7 and 30 days trends
+func history(w http.ResponseWriter, r *http.Request) {
+	if db == nil {
+		http.Error(w, "Storage is disabled", http.StatusNotFound)
+		return
+	}
+	h, err := db.History(r.FormValue("asin"))
+	if err == store.ErrNotFound {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, h)
+}
+
+// diff compares the products found by two stored runs
+func diff(w http.ResponseWriter, r *http.Request) {
+	if db == nil {
+		http.Error(w, "Storage is disabled", http.StatusNotFound)
+		return
+	}
+	from, to := r.FormValue("from"), r.FormValue("to")
+	if from == "" || to == "" {
+		http.Error(w, "Both from and to runs are required", http.StatusBadRequest)
+		return
+	}
+	d, err := db.Diff(from, to)
+	if err == store.ErrNotFound {
+		http.Error(w, "Run not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, d)
+}
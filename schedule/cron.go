@@ -0,0 +1,175 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// field is the set of allowed values of a cron field, bit n is set when n is allowed
+type field uint64
+
+// has tells if the value is allowed
+func (f field) has(n int) bool {
+	return f&(1<<uint(n)) != 0
+}
+
+// fieldRange is the name and the bounds of a cron field
+type fieldRange struct {
+	name     string
+	min, max int
+}
+
+// These are the five fields of a cron expression in their order
+var fieldRanges = [5]fieldRange{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	// Sunday is either 0 or 7 so ranges like 5-7 work
+	{"day of week", 0, 7},
+}
+
+// shortcuts are the named cron expressions
+var shortcuts = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// maxYears is how far Next looks for a matching time, a day like February 30 never comes
+const maxYears = 5
+
+// Cron is a parsed cron expression: minute, hour, day of month, month and day of week
+// Every field is *, a value, a range like 1-5 or a list of them, each with an optional step like */15
+// As in cron, when both days are restricted a time matches if either of them does
+type Cron struct {
+	minute, hour, dom, month, dow field
+	// anyDOM and anyDOW tell if the day fields start with *
+	anyDOM, anyDOW bool
+}
+
+// ParseCron parses a cron expression like '30 6 * * 1-5' or one of @hourly, @daily, @weekly and @monthly
+func ParseCron(spec string) (Cron, error) {
+	spec = strings.TrimSpace(spec)
+	if s, ok := shortcuts[spec]; ok {
+		spec = s
+	}
+	parts := strings.Fields(spec)
+	if len(parts) != len(fieldRanges) {
+		return Cron{}, fmt.Errorf("expected 5 fields (minute hour day month weekday), got %d", len(parts))
+	}
+	var fields [5]field
+	for i, part := range parts {
+		f, err := parseField(part, fieldRanges[i])
+		if err != nil {
+			return Cron{}, err
+		}
+		fields[i] = f
+	}
+	return Cron{
+		minute: fields[0],
+		hour:   fields[1],
+		dom:    fields[2],
+		month:  fields[3],
+		dow:    fields[4],
+		anyDOM: strings.HasPrefix(parts[2], "*"),
+		anyDOW: strings.HasPrefix(parts[4], "*"),
+	}, nil
+}
+
+// parseField parses a comma separated list of values, ranges and steps
+func parseField(part string, r fieldRange) (field, error) {
+	var f field
+	for _, item := range strings.Split(part, ",") {
+		step := 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			s, err := strconv.Atoi(item[i+1:])
+			if err != nil || s < 1 {
+				return 0, fmt.Errorf("invalid step in %s field: %s", r.name, item)
+			}
+			step = s
+			item = item[:i]
+		}
+		lo, hi := r.min, r.max
+		switch {
+		case item == "*":
+		case strings.Contains(item, "-"):
+			bounds := strings.SplitN(item, "-", 2)
+			var err error
+			if lo, err = parseValue(bounds[0], r); err != nil {
+				return 0, err
+			}
+			if hi, err = parseValue(bounds[1], r); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range in %s field: %s", r.name, item)
+			}
+		default:
+			v, err := parseValue(item, r)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			// A single value with a step runs up to the end like in cron, e.g. 5/15
+			if step == 1 {
+				hi = v
+			}
+		}
+		for n := lo; n <= hi; n += step {
+			f |= 1 << uint(n)
+		}
+	}
+	// Times only have Sunday as 0 so a 7 is folded onto it
+	if r.name == "day of week" && f.has(7) {
+		f = f&^(1<<7) | 1
+	}
+	return f, nil
+}
+
+// parseValue parses a value of a field checking its bounds
+func parseValue(s string, r fieldRange) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < r.min || n > r.max {
+		return 0, fmt.Errorf("invalid %s: %s (must be between %d and %d)", r.name, s, r.min, r.max)
+	}
+	return n, nil
+}
+
+// Match tells if the minute of the time is one of the scheduled ones
+func (c Cron) Match(t time.Time) bool {
+	return c.minute.has(t.Minute()) && c.hour.has(t.Hour()) && c.month.has(int(t.Month())) && c.matchDay(t)
+}
+
+// matchDay checks the day of month and day of week fields
+func (c Cron) matchDay(t time.Time) bool {
+	dom, dow := c.dom.has(t.Day()), c.dow.has(int(t.Weekday()))
+	if c.anyDOM || c.anyDOW {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the first scheduled minute after the time, the zero time if there is none
+func (c Cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	end := t.AddDate(maxYears, 0, 0)
+	for t.Before(end) {
+		switch {
+		case !c.month.has(int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !c.hour.has(t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !c.minute.has(t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,142 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+// values lists the allowed values of a field between the bounds
+func values(f field, r fieldRange) []int {
+	var vs []int
+	for n := r.min; n <= r.max; n++ {
+		if f.has(n) {
+			vs = append(vs, n)
+		}
+	}
+	return vs
+}
+
+func TestParseField(t *testing.T) {
+	minute, hour, dow := fieldRanges[0], fieldRanges[1], fieldRanges[4]
+	tests := []struct {
+		part string
+		r    fieldRange
+		want []int
+	}{
+		{"5", minute, []int{5}},
+		{"1-4", hour, []int{1, 2, 3, 4}},
+		{"*/15", minute, []int{0, 15, 30, 45}},
+		{"10-20/5", minute, []int{10, 15, 20}},
+		{"50/3", minute, []int{50, 53, 56, 59}},
+		{"1,3,5", hour, []int{1, 3, 5}},
+		{"1-2,20-23/2", hour, []int{1, 2, 20, 22}},
+		{"0,30,30", minute, []int{0, 30}},
+		// Sunday is both 0 and 7
+		{"7", dow, []int{0}},
+		{"5-7", dow, []int{0, 5, 6}},
+		{"0,7", dow, []int{0}},
+		{"*", dow, []int{0, 1, 2, 3, 4, 5, 6}},
+	}
+	for _, tt := range tests {
+		f, err := parseField(tt.part, tt.r)
+		if err != nil {
+			t.Errorf("parseField(%q) for %s failed: %v", tt.part, tt.r.name, err)
+			continue
+		}
+		got := values(f, tt.r)
+		if len(got) != len(tt.want) {
+			t.Errorf("parseField(%q) for %s = %v, want %v", tt.part, tt.r.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("parseField(%q) for %s = %v, want %v", tt.part, tt.r.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	tests := []struct {
+		spec string
+		err  string
+	}{
+		{"", "expected 5 fields (minute hour day month weekday), got 0"},
+		{"* * * *", "expected 5 fields (minute hour day month weekday), got 4"},
+		{"* * * * * *", "expected 5 fields (minute hour day month weekday), got 6"},
+		{"@yearly", "expected 5 fields (minute hour day month weekday), got 1"},
+		{"60 * * * *", "invalid minute: 60 (must be between 0 and 59)"},
+		{"* 24 * * *", "invalid hour: 24 (must be between 0 and 23)"},
+		{"* * 0 * *", "invalid day of month: 0 (must be between 1 and 31)"},
+		{"* * * 13 *", "invalid month: 13 (must be between 1 and 12)"},
+		{"* * * * 8", "invalid day of week: 8 (must be between 0 and 7)"},
+		{"* * * * mon", "invalid day of week: mon (must be between 0 and 7)"},
+		{"5-1 * * * *", "invalid range in minute field: 5-1"},
+		{"*/0 * * * *", "invalid step in minute field: */0"},
+		{"*/x * * * *", "invalid step in minute field: */x"},
+		{"1- * * * *", "invalid minute:  (must be between 0 and 59)"},
+		{"1,,2 * * * *", "invalid minute:  (must be between 0 and 59)"},
+	}
+	for _, tt := range tests {
+		_, err := ParseCron(tt.spec)
+		if err == nil {
+			t.Errorf("ParseCron(%q) succeeded, want error %q", tt.spec, tt.err)
+			continue
+		}
+		if err.Error() != tt.err {
+			t.Errorf("ParseCron(%q) failed with %q, want %q", tt.spec, err, tt.err)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// 2026-03-02 is a Monday
+	from := time.Date(2026, 3, 2, 10, 17, 42, 0, time.UTC)
+	tests := []struct {
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{"* * * * *", from, time.Date(2026, 3, 2, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", from, time.Date(2026, 3, 2, 10, 30, 0, 0, time.UTC)},
+		{"@hourly", from, time.Date(2026, 3, 2, 11, 0, 0, 0, time.UTC)},
+		{"@daily", from, time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", from, time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", from, time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 6 * * 1-5", from, time.Date(2026, 3, 3, 6, 30, 0, 0, time.UTC)},
+		{"0 9 * * 6,7", from, time.Date(2026, 3, 7, 9, 0, 0, 0, time.UTC)},
+		// 7 is Sunday like 0
+		{"0 0 * * 7", from, time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 5-7", time.Date(2026, 3, 7, 12, 0, 0, 0, time.UTC), time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC)},
+		// A time exactly on the schedule gives the following one
+		{"17 10 * * *", time.Date(2026, 3, 2, 10, 17, 0, 0, time.UTC), time.Date(2026, 3, 3, 10, 17, 0, 0, time.UTC)},
+		// When both days are restricted either of them matches
+		{"0 0 13 * 5", from, time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC)},
+		{"0 0 3 * 5", from, time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC)},
+		// A restricted day with a * day only matches the restricted one
+		{"0 0 13 * *", from, time.Date(2026, 3, 13, 0, 0, 0, 0, time.UTC)},
+		// A day starting with * counts as one even with a step, as in cron, so both must match
+		{"0 0 */10 * 5", from, time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)},
+		// Months and years roll over
+		{"0 0 1 1 *", from, time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 29 2 *", from, time.Date(2028, 2, 29, 12, 0, 0, 0, time.UTC)},
+		{"59 23 31 12 *", time.Date(2026, 12, 31, 23, 59, 0, 0, time.UTC), time.Date(2027, 12, 31, 23, 59, 0, 0, time.UTC)},
+		// A day that never comes gives no time
+		{"0 0 30 2 *", from, time.Time{}},
+	}
+	for _, tt := range tests {
+		c, err := ParseCron(tt.spec)
+		if err != nil {
+			t.Errorf("ParseCron(%q) failed: %v", tt.spec, err)
+			continue
+		}
+		got := c.Next(tt.from)
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseCron(%q).Next(%s) = %s, want %s", tt.spec, tt.from, got, tt.want)
+		}
+		if !got.IsZero() && !c.Match(got) {
+			t.Errorf("ParseCron(%q) does not match its next time %s", tt.spec, got)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// Package schedule runs the saved searches on a cron like schedule without a browser
+// The crawls are recorded and notified like the ones started from the search page
+package schedule
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iulianclita/amazonsurfer/crawler"
+	"github.com/iulianclita/amazonsurfer/store"
+)
+
+// ErrRunning is returned when a schedule is started while its previous crawl is not over
+var ErrRunning = errors.New("the schedule is already running")
+
+// Scheduler starts the crawls of the schedules when they are due
+// Every crawl gets its own crawler so they can run along with the one of the search page
+type Scheduler struct {
+	Store *store.Store
+	// NewCrawler makes the crawler of a scheduled search, configured like the one of the search page
+	NewCrawler func() *crawler.Crawler
+	mu         sync.Mutex
+	running    map[string]bool
+}
+
+// Status is a schedule along with the next time it runs and whether it is running now
+// Next is nil when the schedule is paused
+type Status struct {
+	store.Schedule
+	Next    *time.Time `json:"next"`
+	Running bool       `json:"running"`
+}
+
+// Validate checks a schedule and returns the invalid fields, including the invalid search options
+// The name and cron fields are reported as schedule-name and schedule-cron like the API input names
+func (s *Scheduler) Validate(sch store.Schedule) error {
+	var errs crawler.ValidationError
+	if strings.TrimSpace(sch.Name) == "" {
+		errs = append(errs, crawler.FieldError{Field: "schedule-name", Message: "must not be empty"})
+	}
+	if _, err := ParseCron(sch.Cron); err != nil {
+		errs = append(errs, crawler.FieldError{Field: "schedule-cron", Message: err.Error()})
+	}
+	if err := s.NewCrawler().SetOptions(sch.Options); err != nil {
+		if verrs, ok := err.(crawler.ValidationError); ok {
+			errs = append(errs, verrs...)
+		} else {
+			errs = append(errs, crawler.FieldError{Field: "options", Message: err.Error()})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// List returns the schedules with their status
+func (s *Scheduler) List() ([]Status, error) {
+	schedules, err := s.Store.Schedules()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	list := make([]Status, len(schedules))
+	for i, sch := range schedules {
+		list[i] = s.status(sch, now)
+	}
+	return list, nil
+}
+
+// Status returns a schedule with its status
+func (s *Scheduler) Status(sch store.Schedule) Status {
+	return s.status(sch, time.Now())
+}
+
+// status computes the status of a schedule at a certain time
+func (s *Scheduler) status(sch store.Schedule, now time.Time) Status {
+	st := Status{Schedule: sch}
+	s.mu.Lock()
+	st.Running = s.running[sch.ID]
+	s.mu.Unlock()
+	if c, err := ParseCron(sch.Cron); err == nil && !sch.Paused {
+		if next := c.Next(now); !next.IsZero() {
+			st.Next = &next
+		}
+	}
+	return st
+}
+
+// Run starts the due schedules at the beginning of every minute until stop is closed
+func (s *Scheduler) Run(stop <-chan struct{}) {
+	for {
+		now := time.Now()
+		timer := time.NewTimer(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case t := <-timer.C:
+			s.startDue(t)
+		}
+	}
+}
+
+// startDue starts the schedules which are not paused and due at that minute
+func (s *Scheduler) startDue(t time.Time) {
+	schedules, err := s.Store.Schedules()
+	if err != nil {
+		log.Println("Error reading schedules:", err)
+		return
+	}
+	for _, sch := range schedules {
+		if sch.Paused {
+			continue
+		}
+		c, err := ParseCron(sch.Cron)
+		if err != nil {
+			log.Printf("Invalid schedule %s: %s\n", sch.ID, err)
+			continue
+		}
+		if !c.Match(t) {
+			continue
+		}
+		if err := s.Start(sch); err != nil {
+			log.Printf("Schedule %s not started: %s\n", sch.ID, err)
+		}
+	}
+}
+
+// Start runs the crawl of a schedule in the background, it is also used to run a schedule on demand
+func (s *Scheduler) Start(sch store.Schedule) error {
+	crw := s.NewCrawler()
+	if err := crw.SetOptions(sch.Options); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running[sch.ID] {
+		return ErrRunning
+	}
+	if s.running == nil {
+		s.running = make(map[string]bool)
+	}
+	s.running[sch.ID] = true
+	go s.crawl(sch, crw)
+	return nil
+}
+
+// crawl runs the crawl of a schedule and records it with the schedule
+// Nobody waits for the products on the channel, they are only recorded and notified
+func (s *Scheduler) crawl(sch store.Schedule, crw *crawler.Crawler) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, sch.ID)
+		s.mu.Unlock()
+	}()
+	log.Printf("Schedule %s (%s) started\n", sch.ID, sch.Name)
+	started := time.Now().UTC()
+	prods := make(chan crawler.Product)
+	go crw.Run(nil, prods)
+	for range prods {
+	}
+	rep := crw.Report()
+	log.Printf("Schedule %s finished: %d pages, %d products, %d found, %d errors\n", sch.ID, rep.Pages, rep.Products, rep.Found, rep.Errors)
+	if err := s.Store.ScheduleRan(sch.ID, crw.RunID(), started, crw.Err()); err != nil && err != store.ErrNotFound {
+		log.Println("Error recording schedule:", err)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/iulianclita/amazonsurfer/crawler"
+	"github.com/iulianclita/amazonsurfer/schedule"
+	"github.com/iulianclita/amazonsurfer/store"
+)
+
+// schedules lists the scheduled searches on GET, saves one on POST and removes one on DELETE
+// A schedule is posted like a search with the schedule-name and schedule-cron fields added,
+// along with schedule-id to edit an existing one and schedule-paused to save it paused
+func schedules(w http.ResponseWriter, r *http.Request) {
+	if db == nil {
+		http.Error(w, "Storage is disabled", http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		list, err := scheduler.List()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, list)
+	case http.MethodPost:
+		sch, err := parseSchedule(r)
+		if err != nil {
+			writeErrors(w, err)
+			return
+		}
+		if err := scheduler.Validate(sch); err != nil {
+			writeErrors(w, err)
+			return
+		}
+		sch, err = db.SaveSchedule(sch)
+		if err == store.ErrNotFound {
+			http.Error(w, "Schedule not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, scheduler.Status(sch))
+	case http.MethodDelete:
+		err := db.DeleteSchedule(r.FormValue("id"))
+		if err == store.ErrNotFound {
+			http.Error(w, "Schedule not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, "ok")
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// scheduleFields are the inputs describing the schedule itself, all the others are search options
+var scheduleFields = []string{"schedule-id", "schedule-name", "schedule-cron", "schedule-paused"}
+
+// parseSchedule reads a schedule from a search form or JSON body with the schedule fields added
+func parseSchedule(r *http.Request) (store.Schedule, error) {
+	values, err := crawler.RequestValues(r)
+	if err != nil {
+		return store.Schedule{}, err
+	}
+	paused := values.Get("schedule-paused")
+	sch := store.Schedule{
+		ID:     values.Get("schedule-id"),
+		Name:   strings.TrimSpace(values.Get("schedule-name")),
+		Cron:   strings.TrimSpace(values.Get("schedule-cron")),
+		Paused: paused == "on" || paused == "true",
+	}
+	sch.Options = searchValues(values, scheduleFields)
+	return sch, nil
+}
+
+// searchValues returns the search input without the given fields
+func searchValues(values url.Values, fields []string) url.Values {
+	options := url.Values{}
+	for key, vs := range values {
+		options[key] = vs
+	}
+	for _, key := range fields {
+		options.Del(key)
+	}
+	return options
+}
+
+// schedulePause pauses a schedule, or resumes it when paused is false
+func schedulePause(w http.ResponseWriter, r *http.Request) {
+	if db == nil {
+		http.Error(w, "Storage is disabled", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	sch, err := db.PauseSchedule(r.FormValue("id"), r.FormValue("paused") != "false")
+	if err == store.ErrNotFound {
+		http.Error(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, scheduler.Status(sch))
+}
+
+// scheduleRun starts the crawl of a schedule right away, paused or not
+// It answers with a conflict status while the previous crawl of the schedule is not over
+func scheduleRun(w http.ResponseWriter, r *http.Request) {
+	if db == nil {
+		http.Error(w, "Storage is disabled", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	sch, err := db.Schedule(r.FormValue("id"))
+	if err == store.ErrNotFound {
+		http.Error(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	err = scheduler.Start(sch)
+	if err == schedule.ErrRunning {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		writeErrors(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, scheduler.Status(sch))
+}
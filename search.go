@@ -0,0 +1,151 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/iulianclita/amazonsurfer/crawler"
+	"github.com/iulianclita/amazonsurfer/store"
+)
+
+// We do not have a favicon so send 404 response
+func favicon(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+}
+
+// Index loads the home page and fills the parsed template with all the necessary data
+func index(w http.ResponseWriter, r *http.Request) {
+	data := struct {
+		Host       string
+		Categories map[uint8]string
+	}{
+		Host:       r.Host,
+		Categories: crawler.GetCategories(),
+	}
+
+	tpl.Execute(w, data)
+}
+
+// search attaches useful data from the request to the existing web crawler
+// The crawler stores that data into its options property
+// Invalid input is answered with a 400 status and the list of invalid fields as JSON
+// With a preset id the search input saved in the preset is used and the other fields are ignored
+func search(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	// The preset id comes in the form or in the JSON body like the rest of the search input
+	values, err := crawler.RequestValues(r)
+	if err != nil {
+		writeErrors(w, err)
+		return
+	}
+	if id := values.Get("preset"); id != "" {
+		searchPreset(w, id)
+		return
+	}
+	if err := crw.SetOptions(values); err != nil {
+		log.Println("Search error:", err)
+		writeErrors(w, err)
+		return
+	}
+	io.WriteString(w, "ok")
+}
+
+// searchPreset attaches the search input saved in a preset to the web crawler
+func searchPreset(w http.ResponseWriter, id string) {
+	if db == nil {
+		http.Error(w, "Storage is disabled", http.StatusNotFound)
+		return
+	}
+	p, err := db.Preset(id)
+	if err == store.ErrNotFound {
+		http.Error(w, "Preset not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := crw.SetOptions(p.Options); err != nil {
+		log.Println("Search error:", err)
+		writeErrors(w, err)
+		return
+	}
+	io.WriteString(w, "ok")
+}
+
+// Here is the core processing where the lookup is made
+// We start by upgrading our connection to websockets
+// After we launch the crawler in the background to search for products
+// We wait for products to be sent in the main goroutine and flush them in the frontend
+func start(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader already answered the client with an error status
+		log.Println("Socket error:", err)
+		return
+	}
+	defer conn.Close()
+	// This channel will receive the products from the crawler
+	prods := make(chan crawler.Product)
+	// Run the crawler in the background
+	go crw.Run(conn, prods)
+	// Wait for incoming products
+	for p := range prods {
+		// Check for exit signal before sending the product
+		select {
+		case <-crw.Done:
+			return
+		default:
+			if err := conn.WriteJSON(crawler.Message{Type: "product", Data: p}); err != nil {
+				log.Println("Send error:", err)
+			}
+		}
+	}
+	// The crawl is over so send the parse quality report
+	rep := crw.Report()
+	log.Printf("Crawl finished: %d pages, %d products, %d found, %d errors\n", rep.Pages, rep.Products, rep.Found, rep.Errors)
+	if err := conn.WriteJSON(crawler.Message{Type: "report", Data: rep}); err != nil {
+		log.Println("Send error:", err)
+	}
+	// Send the found products again, this time ranked by score
+	if err := conn.WriteJSON(crawler.Message{Type: "ranking", Data: crw.Ranking()}); err != nil {
+		log.Println("Send error:", err)
+	}
+}
+
+// report sends the parse quality report of the current (or last) crawl as JSON
+func report(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, crw.Report())
+}
+
+// ranking sends the products found by the current (or last) crawl ranked by score as JSON
+func ranking(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, crw.Ranking())
+}
+
+// filter checks a filter expression and answers with the parse error if there is one
+// This way the frontend can validate the expression before starting a search
+func filter(w http.ResponseWriter, r *http.Request) {
+	res := struct {
+		Valid bool   `json:"valid"`
+		Error string `json:"error,omitempty"`
+	}{Valid: true}
+	if _, err := crawler.ParseFilter(r.FormValue("filter")); err != nil {
+		res.Valid = false
+		res.Error = err.Error()
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// stop closses the current websockets connection
+func stop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	crw.Stop()
+}
@@ -0,0 +1,186 @@
+package store
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// These are the buckets of the alert rules and the alerts they raised
+var (
+	// rulesBucket maps a rule id to the rule
+	rulesBucket = []byte("rules")
+	// alertsBucket maps a time ordered key to the alert
+	alertsBucket = []byte("alerts")
+	// lastAlertsBucket maps a rule id and an ASIN to the time the rule last raised an alert for the product
+	lastAlertsBucket = []byte("last-alerts")
+)
+
+// These are the kinds of alert rules
+const (
+	// RuleCondition fires when a product starts matching a filter expression, e.g. 'price < 15'
+	RuleCondition = "condition"
+	// RuleChange fires when a value moved by a percentage over a number of days, e.g. BSR down 30% in 7 days
+	RuleChange = "change"
+	// RuleNew fires when a product matching an optional filter expression is seen for the first time
+	RuleNew = "new"
+)
+
+// Rule describes when an alert is raised
+// Expression is used by the condition and new rules, Metric, Direction, Percent and Days by the change rules
+// Direction is up, down or any and Percent is the minimum change in that direction
+type Rule struct {
+	ID         string  `json:"id"`
+	Name       string  `json:"name"`
+	Kind       string  `json:"kind"`
+	Expression string  `json:"expression"`
+	Metric     string  `json:"metric"`
+	Direction  string  `json:"direction"`
+	Percent    float64 `json:"percent"`
+	Days       int     `json:"days"`
+	Enabled    bool    `json:"enabled"`
+}
+
+// Alert is raised by a rule for a product
+// Run is the crawl (or the watchlist) which fetched the product
+type Alert struct {
+	ID      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	Rule    string    `json:"rule"`
+	Name    string    `json:"name"`
+	ASIN    string    `json:"asin"`
+	Product string    `json:"product"`
+	Link    string    `json:"link"`
+	Run     string    `json:"run"`
+	Message string    `json:"message"`
+}
+
+// SaveRule creates or updates a rule, a new rule gets an id
+func (s *Store) SaveRule(rule Rule) (Rule, error) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(rulesBucket)
+		if err != nil {
+			return err
+		}
+		if rule.ID == "" {
+			seq, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			rule.ID = strconv.FormatUint(seq, 10)
+		} else if b.Get([]byte(rule.ID)) == nil {
+			return ErrNotFound
+		}
+		data, err := json.Marshal(rule)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(rule.ID), data)
+	})
+	return rule, err
+}
+
+// DeleteRule removes a rule, the alerts it raised are kept
+func (s *Store) DeleteRule(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(rulesBucket)
+		if b == nil || b.Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+		return b.Delete([]byte(id))
+	})
+}
+
+// Rules returns all the alert rules
+func (s *Store) Rules() ([]Rule, error) {
+	rules := []Rule{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(rulesBucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var rule Rule
+			if err := json.Unmarshal(v, &rule); err != nil {
+				return err
+			}
+			rules = append(rules, rule)
+			return nil
+		})
+	})
+	return rules, err
+}
+
+// SaveAlert records an alert giving it an id
+func (s *Store) SaveAlert(a Alert) (Alert, error) {
+	a.Time = time.Now().UTC()
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(alertsBucket)
+		if err != nil {
+			return err
+		}
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		a.ID = strconv.FormatUint(seq, 10)
+		data, err := json.Marshal(a)
+		if err != nil {
+			return err
+		}
+		// The time starts the key so the alerts are sorted by the time they were raised
+		if err := b.Put(timeKey(a.Time, a.ID), data); err != nil {
+			return err
+		}
+		return putLastAlert(tx.Bucket(lastAlertsBucket), a)
+	})
+	return a, err
+}
+
+// Alerts returns the most recent alerts first, at most limit of them
+func (s *Store) Alerts(limit int) ([]Alert, error) {
+	alerts := []Alert{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(alertsBucket)
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Last(); k != nil && len(alerts) < limit; k, v = c.Prev() {
+			var a Alert
+			if err := json.Unmarshal(v, &a); err != nil {
+				return err
+			}
+			alerts = append(alerts, a)
+		}
+		return nil
+	})
+	return alerts, err
+}
+
+// LastAlert returns the time a rule last raised an alert for a product, zero when it never did
+func (s *Store) LastAlert(rule, asin string) (time.Time, error) {
+	var last time.Time
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(lastAlertsBucket).Get(lastAlertKey(rule, asin))
+		if v == nil {
+			return nil
+		}
+		t, err := time.Parse(keyTimeFormat, string(v))
+		last = t
+		return err
+	})
+	return last, err
+}
+
+// lastAlertKey is the key of a rule and a product in the last alerts bucket, rule ids never hold a slash
+func lastAlertKey(rule, asin string) []byte {
+	return []byte(rule + "/" + asin)
+}
+
+// putLastAlert records an alert as the last one its rule raised for the product
+func putLastAlert(b *bolt.Bucket, a Alert) error {
+	return b.Put(lastAlertKey(a.Rule, a.ASIN), []byte(a.Time.UTC().Format(keyTimeFormat)))
+}
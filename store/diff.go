@@ -0,0 +1,142 @@
+package store
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/iulianclita/amazonsurfer/crawler"
+)
+
+// FieldChange is a product field which changed between two runs
+type FieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// ProductChange lists the fields of a product which changed between two runs
+type ProductChange struct {
+	ASIN    string        `json:"asin"`
+	Name    string        `json:"name"`
+	Changes []FieldChange `json:"changes"`
+}
+
+// Diff compares the products found by two runs
+// New products passed the filters of the second run only and dropped products the ones of the first run only
+// Changed products passed the filters of both runs but some of their fields changed
+type Diff struct {
+	From    string          `json:"from"`
+	To      string          `json:"to"`
+	New     []Snapshot      `json:"new"`
+	Dropped []Snapshot      `json:"dropped"`
+	Changed []ProductChange `json:"changed"`
+}
+
+// diffFields are the product fields compared between runs
+var diffFields = []struct {
+	name  string
+	value func(p *crawler.Product) string
+}{
+	{"name", func(p *crawler.Product) string { return p.Name }},
+	{"brand", func(p *crawler.Product) string { return p.Brand }},
+	{"price", func(p *crawler.Product) string { return strconv.FormatFloat(p.Price, 'f', 2, 64) }},
+	{"bsr", func(p *crawler.Product) string { return strconv.FormatUint(uint64(p.BSR), 10) }},
+	{"reviews", func(p *crawler.Product) string { return strconv.FormatUint(uint64(p.Reviews), 10) }},
+	{"rating", func(p *crawler.Product) string { return strconv.FormatFloat(p.Rating, 'f', 1, 64) }},
+	{"seller", func(p *crawler.Product) string { return p.Seller }},
+	{"fulfilled", func(p *crawler.Product) string { return p.Fulfilled }},
+	{"offers", func(p *crawler.Product) string { return strconv.FormatUint(uint64(p.Offers), 10) }},
+	{"prime", func(p *crawler.Product) string { return strconv.FormatBool(p.Prime) }},
+	{"variations", func(p *crawler.Product) string { return strconv.FormatUint(uint64(p.Variations), 10) }},
+	{"sales", func(p *crawler.Product) string { return strconv.FormatUint(uint64(p.Sales), 10) }},
+	{"profit", func(p *crawler.Product) string { return strconv.FormatFloat(p.Profit, 'f', 2, 64) }},
+	{"score", func(p *crawler.Product) string { return strconv.FormatFloat(p.Score, 'f', 1, 64) }},
+}
+
+// Diff compares the products found by two runs
+func (s *Store) Diff(from, to string) (Diff, error) {
+	d := Diff{From: from, To: to, New: []Snapshot{}, Dropped: []Snapshot{}, Changed: []ProductChange{}}
+	before, err := s.validProducts(from)
+	if err != nil {
+		return d, err
+	}
+	after, err := s.validProducts(to)
+	if err != nil {
+		return d, err
+	}
+	for asin, snap := range after {
+		old, ok := before[asin]
+		if !ok {
+			d.New = append(d.New, snap)
+			continue
+		}
+		if changes := compare(&old.Product, &snap.Product); len(changes) > 0 {
+			d.Changed = append(d.Changed, ProductChange{ASIN: asin, Name: snap.Product.Name, Changes: changes})
+		}
+	}
+	for asin, snap := range before {
+		if _, ok := after[asin]; !ok {
+			d.Dropped = append(d.Dropped, snap)
+		}
+	}
+	// Maps have no order so sort everything by ASIN to get the same report every time
+	sort.Slice(d.New, func(i, j int) bool { return d.New[i].Product.ASIN < d.New[j].Product.ASIN })
+	sort.Slice(d.Dropped, func(i, j int) bool { return d.Dropped[i].Product.ASIN < d.Dropped[j].Product.ASIN })
+	sort.Slice(d.Changed, func(i, j int) bool { return d.Changed[i].ASIN < d.Changed[j].ASIN })
+	return d, nil
+}
+
+// compare lists the fields which differ between two copies of a product
+func compare(old, prod *crawler.Product) []FieldChange {
+	var changes []FieldChange
+	for _, f := range diffFields {
+		if a, b := f.value(old), f.value(prod); a != b {
+			changes = append(changes, FieldChange{Field: f.name, From: a, To: b})
+		}
+	}
+	return changes
+}
+
+// validProducts returns the snapshots of the products which passed the filters of a run by ASIN
+func (s *Store) validProducts(run string) (map[string]Snapshot, error) {
+	snaps, err := s.RunProducts(run)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]Snapshot)
+	for _, snap := range snaps {
+		if snap.Valid {
+			m[snap.Product.ASIN] = snap
+		}
+	}
+	return m, nil
+}
+
+// WriteReport writes the diff in a human readable way
+func (d Diff) WriteReport(w io.Writer) error {
+	var err error
+	printf := func(format string, args ...interface{}) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+	printf("Comparing run %s with run %s\n", d.From, d.To)
+	printf("\nNew products (%d)\n", len(d.New))
+	for _, snap := range d.New {
+		printf("  + %s %s\n", snap.Product.ASIN, snap.Product.Name)
+	}
+	printf("\nDropped products (%d)\n", len(d.Dropped))
+	for _, snap := range d.Dropped {
+		printf("  - %s %s\n", snap.Product.ASIN, snap.Product.Name)
+	}
+	printf("\nChanged products (%d)\n", len(d.Changed))
+	for _, pc := range d.Changed {
+		printf("  ~ %s %s\n", pc.ASIN, pc.Name)
+		for _, c := range pc.Changes {
+			printf("      %s: %s -> %s\n", c.Field, c.From, c.To)
+		}
+	}
+	return err
+}
@@ -0,0 +1,188 @@
+package store
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Locale tells how the numbers of an export are written
+// Spreadsheets of locales using a decimal comma expect the cells to be separated by semicolons
+type Locale struct {
+	Decimal   string
+	Separator rune
+}
+
+// These are the number formats of the exports
+var (
+	// DecimalPoint is used by English and most other locales, e.g. 1234.5
+	DecimalPoint = Locale{Decimal: ".", Separator: ','}
+	// DecimalComma is used by most of continental Europe and South America, e.g. 1234,5
+	DecimalComma = Locale{Decimal: ",", Separator: ';'}
+)
+
+// decimalCommaLanguages are the languages writing numbers with a decimal comma
+var decimalCommaLanguages = map[string]bool{
+	"bg": true, "ca": true, "cs": true, "da": true, "de": true, "el": true, "es": true, "et": true,
+	"fi": true, "fr": true, "hr": true, "hu": true, "id": true, "it": true, "lt": true, "lv": true,
+	"nb": true, "nl": true, "nn": true, "no": true, "pl": true, "pt": true, "ro": true, "ru": true,
+	"sk": true, "sl": true, "sr": true, "sv": true, "tr": true, "uk": true, "vi": true,
+}
+
+// localePattern matches a language tag like en, en-US, pt_BR or de-DE
+var localePattern = regexp.MustCompile(`^([A-Za-z]{2,3})([-_][A-Za-z0-9]+)*$`)
+
+// ParseLocale returns the number format of a language tag like de-DE, an empty tag uses the decimal point
+func ParseLocale(tag string) (Locale, error) {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return DecimalPoint, nil
+	}
+	m := localePattern.FindStringSubmatch(tag)
+	if m == nil {
+		return Locale{}, fmt.Errorf("invalid locale %s, expected a language tag like en-US", tag)
+	}
+	if decimalCommaLanguages[strings.ToLower(m[1])] {
+		return DecimalComma, nil
+	}
+	return DecimalPoint, nil
+}
+
+// number formats a number with the given decimals, -1 uses as many as needed
+func (l Locale) number(f float64, decimals int) string {
+	return strings.Replace(strconv.FormatFloat(f, 'f', decimals, 64), ".", l.Decimal, 1)
+}
+
+// column is a column of an export
+// field is the product field which must have been found for the value to be written, none when empty
+type column struct {
+	name  string
+	field string
+	value func(snap Snapshot, l Locale) string
+}
+
+// columns are all the columns of an export in their order
+var columns = []column{
+	{"run", "", func(s Snapshot, l Locale) string { return s.Run }},
+	{"time", "", func(s Snapshot, l Locale) string { return s.Time.Format(time.RFC3339) }},
+	{"asin", "", func(s Snapshot, l Locale) string { return s.Product.ASIN }},
+	{"name", "", func(s Snapshot, l Locale) string { return s.Product.Name }},
+	{"brand", "", func(s Snapshot, l Locale) string { return s.Product.Brand }},
+	{"link", "", func(s Snapshot, l Locale) string { return s.Product.Link }},
+	{"category", "", func(s Snapshot, l Locale) string { return s.Product.Category }},
+	{"price", "price", func(s Snapshot, l Locale) string { return l.number(s.Product.Price, 2) }},
+	{"bsr", "bsr", func(s Snapshot, l Locale) string { return strconv.FormatUint(uint64(s.Product.BSR), 10) }},
+	{"reviews", "reviews", func(s Snapshot, l Locale) string { return strconv.FormatUint(uint64(s.Product.Reviews), 10) }},
+	{"rating", "rating", func(s Snapshot, l Locale) string { return l.number(s.Product.Rating, 1) }},
+	{"histogram", "histogram", func(s Snapshot, l Locale) string {
+		h := make([]string, len(s.Product.Histogram))
+		for i, pct := range s.Product.Histogram {
+			h[i] = strconv.FormatUint(uint64(pct), 10)
+		}
+		return strings.Join(h, "/")
+	}},
+	{"length", "length", func(s Snapshot, l Locale) string { return l.number(s.Product.Length, -1) }},
+	{"width", "width", func(s Snapshot, l Locale) string { return l.number(s.Product.Width, -1) }},
+	{"height", "height", func(s Snapshot, l Locale) string { return l.number(s.Product.Height, -1) }},
+	{"weight", "weight", func(s Snapshot, l Locale) string { return l.number(s.Product.Weight, -1) }},
+	{"seller", "seller", func(s Snapshot, l Locale) string { return s.Product.Seller }},
+	{"fulfilled", "fulfilled", func(s Snapshot, l Locale) string { return s.Product.Fulfilled }},
+	{"offers", "offers", func(s Snapshot, l Locale) string { return strconv.FormatUint(uint64(s.Product.Offers), 10) }},
+	{"prime", "", func(s Snapshot, l Locale) string { return strconv.FormatBool(s.Product.Prime) }},
+	{"image", "", func(s Snapshot, l Locale) string { return s.Product.Image }},
+	{"images", "", func(s Snapshot, l Locale) string { return strconv.FormatUint(uint64(s.Product.Images), 10) }},
+	{"parent", "", func(s Snapshot, l Locale) string { return s.Product.Parent }},
+	{"variations", "", func(s Snapshot, l Locale) string { return strconv.FormatUint(uint64(s.Product.Variations), 10) }},
+	{"dimensions", "", func(s Snapshot, l Locale) string { return strings.Join(s.Product.Dimensions, " / ") }},
+	{"missing", "", func(s Snapshot, l Locale) string {
+		var missing []string
+		for field := range s.Product.Status {
+			if !s.Product.Found(field) {
+				missing = append(missing, field)
+			}
+		}
+		sort.Strings(missing)
+		return strings.Join(missing, " ")
+	}},
+	{"unknown", "", func(s Snapshot, l Locale) string { return strings.Join(s.Product.Unknown, " ") }},
+	{"score", "", func(s Snapshot, l Locale) string { return l.number(s.Product.Score, 1) }},
+	{"sales", "bsr", func(s Snapshot, l Locale) string { return strconv.FormatUint(uint64(s.Product.Sales), 10) }},
+	{"revenue", "bsr", func(s Snapshot, l Locale) string { return l.number(s.Product.Revenue, 2) }},
+	{"sizeTier", "", func(s Snapshot, l Locale) string { return s.Product.SizeTier }},
+	{"referralFee", "price", func(s Snapshot, l Locale) string { return l.number(s.Product.ReferralFee, 2) }},
+	{"fulfilmentFee", "", func(s Snapshot, l Locale) string { return l.number(s.Product.FulfilmentFee, 2) }},
+	{"cost", "", func(s Snapshot, l Locale) string { return l.number(s.Product.Cost, 2) }},
+	{"profit", "price", func(s Snapshot, l Locale) string { return l.number(s.Product.Profit, 2) }},
+	{"margin", "price", func(s Snapshot, l Locale) string { return l.number(s.Product.Margin, 2) }},
+	{"roi", "price", func(s Snapshot, l Locale) string { return l.number(s.Product.ROI, 2) }},
+	{"valid", "", func(s Snapshot, l Locale) string { return strconv.FormatBool(s.Valid) }},
+	{"rejections", "", func(s Snapshot, l Locale) string {
+		rejections := make([]string, len(s.Product.Rejections))
+		for i, rej := range s.Product.Rejections {
+			rejections[i] = rej.String()
+		}
+		return strings.Join(rejections, "; ")
+	}},
+}
+
+// ExportColumns returns the names of all the columns of an export in their order
+func ExportColumns() []string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.name
+	}
+	return names
+}
+
+// WriteCSV writes the snapshots as CSV with a header row, using all the columns when none are given
+// The values of fields which were not found on the product page are left empty
+func WriteCSV(w io.Writer, snaps []Snapshot, names []string, l Locale) error {
+	cols := columns
+	if len(names) > 0 {
+		cols = make([]column, 0, len(names))
+		for _, name := range names {
+			c, ok := findColumn(name)
+			if !ok {
+				return fmt.Errorf("unknown column %s, expected one of %s", name, strings.Join(ExportColumns(), ", "))
+			}
+			cols = append(cols, c)
+		}
+	}
+	cw := csv.NewWriter(w)
+	cw.Comma = l.Separator
+	record := make([]string, len(cols))
+	for i, c := range cols {
+		record[i] = c.name
+	}
+	if err := cw.Write(record); err != nil {
+		return err
+	}
+	for _, snap := range snaps {
+		for i, c := range cols {
+			record[i] = ""
+			if c.field == "" || snap.Product.Found(c.field) {
+				record[i] = c.value(snap, l)
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// findColumn returns a column by its name, ignoring the case
+func findColumn(name string) (column, bool) {
+	for _, c := range columns {
+		if strings.EqualFold(c.name, strings.TrimSpace(name)) {
+			return c, true
+		}
+	}
+	return column{}, false
+}
@@ -0,0 +1,141 @@
+package store
+
+import (
+	"math"
+	"time"
+)
+
+// trendDays are the periods the trends of a product are computed over
+var trendDays = []int{7, 30}
+
+// Point holds the tracked values of a product at the time of a snapshot
+// A value is null when its field was not found on the product page
+type Point struct {
+	Time    time.Time `json:"time"`
+	Run     string    `json:"run"`
+	Price   *float64  `json:"price"`
+	BSR     *float64  `json:"bsr"`
+	Reviews *float64  `json:"reviews"`
+	Rating  *float64  `json:"rating"`
+}
+
+// Change is how much a value moved over a period
+// Percent is relative to the value at the start of the period and 0 when that value is 0
+type Change struct {
+	From    float64 `json:"from"`
+	To      float64 `json:"to"`
+	Delta   float64 `json:"delta"`
+	Percent float64 `json:"percent"`
+}
+
+// Trend holds the changes of the tracked values over a number of days
+// A change is null when the value was not seen at least twice in the period
+type Trend struct {
+	Days    int     `json:"days"`
+	Price   *Change `json:"price"`
+	BSR     *Change `json:"bsr"`
+	Reviews *Change `json:"reviews"`
+	Rating  *Change `json:"rating"`
+}
+
+// History is the time series of a product over every crawl that fetched it
+type History struct {
+	ASIN   string  `json:"asin"`
+	Name   string  `json:"name"`
+	Link   string  `json:"link"`
+	Image  string  `json:"image"`
+	Points []Point `json:"points"`
+	Trends []Trend `json:"trends"`
+}
+
+// History returns the time series of a product along with its trends
+func (s *Store) History(asin string) (History, error) {
+	snaps, err := s.Snapshots(asin)
+	if err != nil {
+		return History{}, err
+	}
+	return NewHistory(asin, snaps, time.Now().UTC()), nil
+}
+
+// NewHistory builds the time series from the snapshots ordered by time
+// The trends are computed up to now
+func NewHistory(asin string, snaps []Snapshot, now time.Time) History {
+	h := History{ASIN: asin, Points: make([]Point, 0, len(snaps))}
+	for _, snap := range snaps {
+		prod := snap.Product
+		// The latest snapshot tells how the product looks now
+		h.Name, h.Link, h.Image = prod.Name, prod.Link, prod.Image
+		value := func(field string, v float64) *float64 {
+			if !prod.Found(field) {
+				return nil
+			}
+			return &v
+		}
+		h.Points = append(h.Points, Point{
+			Time:    snap.Time,
+			Run:     snap.Run,
+			Price:   value("price", prod.Price),
+			BSR:     value("bsr", float64(prod.BSR)),
+			Reviews: value("reviews", float64(prod.Reviews)),
+			Rating:  value("rating", prod.Rating),
+		})
+	}
+	for _, days := range trendDays {
+		h.Trends = append(h.Trends, Trend{
+			Days:    days,
+			Price:   h.Change("price", days, now),
+			BSR:     h.Change("bsr", days, now),
+			Reviews: h.Change("reviews", days, now),
+			Rating:  h.Change("rating", days, now),
+		})
+	}
+	return h
+}
+
+// Metrics maps the tracked values to their point field
+var Metrics = map[string]func(Point) *float64{
+	"price":   func(p Point) *float64 { return p.Price },
+	"bsr":     func(p Point) *float64 { return p.BSR },
+	"reviews": func(p Point) *float64 { return p.Reviews },
+	"rating":  func(p Point) *float64 { return p.Rating },
+}
+
+// Change returns how much a metric moved over the days before now
+// It is nil for unknown metrics or when the value was not seen at least twice in the period
+func (h History) Change(metric string, days int, now time.Time) *Change {
+	value, ok := Metrics[metric]
+	if !ok {
+		return nil
+	}
+	return change(h.Points, now.AddDate(0, 0, -days), value)
+}
+
+// change compares the latest value with the one at the start of the period
+// The start value is the last one seen before the period or else the first one seen in it
+func change(points []Point, since time.Time, value func(Point) *float64) *Change {
+	var from, to *float64
+	for _, p := range points {
+		v := value(p)
+		if v == nil {
+			continue
+		}
+		if p.Time.Before(since) || from == nil {
+			from = v
+		}
+		to = v
+	}
+	// The latest value must fall in the period or nothing changed as far as we know
+	if from == nil || from == to {
+		return nil
+	}
+	c := &Change{From: *from, To: *to, Delta: round2(*to - *from)}
+	if *from != 0 {
+		c.Percent = round2(100 * (*to - *from) / *from)
+	}
+	return c
+}
+
+// round2 rounds the changes to 2 decimals
+func round2(f float64) float64 {
+	return math.Round(100*f) / 100
+}
@@ -0,0 +1,98 @@
+package store
+
+import (
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// presetsBucket maps a preset id to the preset
+var presetsBucket = []byte("presets")
+
+// Preset is a named search input, the same values the search form sends
+type Preset struct {
+	ID      string     `json:"id"`
+	Name    string     `json:"name"`
+	Options url.Values `json:"options"`
+	Saved   time.Time  `json:"saved"`
+}
+
+// SavePreset creates or updates a preset, a new preset gets an id
+func (s *Store) SavePreset(p Preset) (Preset, error) {
+	p.Saved = time.Now().UTC()
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(presetsBucket)
+		if err != nil {
+			return err
+		}
+		if p.ID == "" {
+			seq, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			p.ID = strconv.FormatUint(seq, 10)
+		} else if b.Get([]byte(p.ID)) == nil {
+			return ErrNotFound
+		}
+		data, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(p.ID), data)
+	})
+	return p, err
+}
+
+// DeletePreset removes a preset
+func (s *Store) DeletePreset(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(presetsBucket)
+		if b == nil || b.Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+		return b.Delete([]byte(id))
+	})
+}
+
+// Preset returns a preset by its id
+func (s *Store) Preset(id string) (Preset, error) {
+	var p Preset
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(presetsBucket)
+		if b == nil {
+			return ErrNotFound
+		}
+		data := b.Get([]byte(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(data, &p)
+	})
+	return p, err
+}
+
+// Presets returns all the presets sorted by name
+func (s *Store) Presets() ([]Preset, error) {
+	presets := []Preset{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(presetsBucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var p Preset
+			if err := json.Unmarshal(v, &p); err != nil {
+				return err
+			}
+			presets = append(presets, p)
+			return nil
+		})
+	})
+	sort.Slice(presets, func(i, j int) bool { return strings.ToLower(presets[i].Name) < strings.ToLower(presets[j].Name) })
+	return presets, err
+}
@@ -0,0 +1,155 @@
+package store
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// schedulesBucket maps a schedule id to the schedule
+var schedulesBucket = []byte("schedules")
+
+// Schedule is a search run by the server on a cron like schedule, e.g. '0 6 * * *' every day at 6
+// Options are the search input, the same values the search form sends
+// LastRun, LastStarted and LastError describe the last crawl the schedule started
+type Schedule struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Cron        string     `json:"cron"`
+	Options     url.Values `json:"options"`
+	Paused      bool       `json:"paused"`
+	LastRun     string     `json:"lastRun"`
+	LastStarted time.Time  `json:"lastStarted"`
+	LastError   string     `json:"lastError"`
+}
+
+// SaveSchedule creates or updates a schedule, a new schedule gets an id
+// Updating a schedule keeps what is known about its last crawl
+func (s *Store) SaveSchedule(sch Schedule) (Schedule, error) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(schedulesBucket)
+		if err != nil {
+			return err
+		}
+		if sch.ID == "" {
+			seq, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			sch.ID = strconv.FormatUint(seq, 10)
+		} else {
+			data := b.Get([]byte(sch.ID))
+			if data == nil {
+				return ErrNotFound
+			}
+			var old Schedule
+			if err := json.Unmarshal(data, &old); err != nil {
+				return err
+			}
+			sch.LastRun, sch.LastStarted, sch.LastError = old.LastRun, old.LastStarted, old.LastError
+		}
+		return putSchedule(b, sch)
+	})
+	return sch, err
+}
+
+// DeleteSchedule removes a schedule, the runs it started are kept
+func (s *Store) DeleteSchedule(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(schedulesBucket)
+		if b == nil || b.Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+		return b.Delete([]byte(id))
+	})
+}
+
+// Schedule returns a schedule by its id
+func (s *Store) Schedule(id string) (Schedule, error) {
+	var sch Schedule
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(schedulesBucket)
+		if b == nil {
+			return ErrNotFound
+		}
+		data := b.Get([]byte(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(data, &sch)
+	})
+	return sch, err
+}
+
+// Schedules returns all the schedules
+func (s *Store) Schedules() ([]Schedule, error) {
+	schedules := []Schedule{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(schedulesBucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var sch Schedule
+			if err := json.Unmarshal(v, &sch); err != nil {
+				return err
+			}
+			schedules = append(schedules, sch)
+			return nil
+		})
+	})
+	return schedules, err
+}
+
+// PauseSchedule pauses or resumes a schedule
+func (s *Store) PauseSchedule(id string, paused bool) (Schedule, error) {
+	return s.updateSchedule(id, func(sch *Schedule) {
+		sch.Paused = paused
+	})
+}
+
+// ScheduleRan records the crawl a schedule started and the reason it failed if it did
+func (s *Store) ScheduleRan(id, run string, started time.Time, err error) error {
+	_, e := s.updateSchedule(id, func(sch *Schedule) {
+		sch.LastRun = run
+		sch.LastStarted = started
+		sch.LastError = ""
+		if err != nil {
+			sch.LastError = err.Error()
+		}
+	})
+	return e
+}
+
+// updateSchedule changes a stored schedule
+func (s *Store) updateSchedule(id string, update func(sch *Schedule)) (Schedule, error) {
+	var sch Schedule
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(schedulesBucket)
+		if b == nil {
+			return ErrNotFound
+		}
+		data := b.Get([]byte(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		if err := json.Unmarshal(data, &sch); err != nil {
+			return err
+		}
+		update(&sch)
+		return putSchedule(b, sch)
+	})
+	return sch, err
+}
+
+// putSchedule saves a schedule under its id
+func putSchedule(b *bolt.Bucket, sch Schedule) error {
+	data, err := json.Marshal(sch)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(sch.ID), data)
+}
@@ -0,0 +1,228 @@
+// Package store keeps the crawled products and the runs that found them in an embedded database
+// Every product fetched by a crawl is saved as a timestamped snapshot under its ASIN
+// so the history of a product can be rebuilt and past research revisited
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/iulianclita/amazonsurfer/crawler"
+	bolt "go.etcd.io/bbolt"
+)
+
+// These are the buckets of the database
+var (
+	// runsBucket maps a run id to its metadata
+	runsBucket = []byte("runs")
+	// productsBucket holds a nested bucket for every ASIN mapping the snapshot key to the snapshot
+	productsBucket = []byte("products")
+	// runProductsBucket holds a nested bucket for every run mapping the ASIN to the snapshot key
+	runProductsBucket = []byte("run-products")
+)
+
+// keyTimeFormat has a fixed width so the keys starting with a time sort in time order
+// RFC3339Nano drops the trailing zeros of the fraction so it does not
+const keyTimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+// timeKey makes a key sorting by time, the id keeps apart the keys made at the same time
+func timeKey(t time.Time, id string) []byte {
+	return []byte(t.UTC().Format(keyTimeFormat) + "/" + id)
+}
+
+// ErrNotFound is returned when a run or product is not in the database
+var ErrNotFound = errors.New("not found")
+
+// ErrLocked is returned when the database file is open in another process, usually the running server
+var ErrLocked = errors.New("the database is in use by another process")
+
+// Run describes a crawl along with the search options it used and its report
+type Run struct {
+	ID       string         `json:"id"`
+	Started  time.Time      `json:"started"`
+	Finished time.Time      `json:"finished"`
+	Options  url.Values     `json:"options"`
+	Report   crawler.Report `json:"report"`
+}
+
+// Snapshot is a product as it was fetched by a run at a certain time
+// Valid tells if the product passed the filters of the run
+type Snapshot struct {
+	Run     string          `json:"run"`
+	Time    time.Time       `json:"time"`
+	Valid   bool            `json:"valid"`
+	Product crawler.Product `json:"product"`
+}
+
+// Store is the embedded database holding the runs and product snapshots
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens the database file creating it and its buckets when needed
+// Only one process can have the file open, the others get ErrLocked
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err == bolt.ErrTimeout {
+		return nil, ErrLocked
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{runsBucket, productsBucket, runProductsBucket, lastAlertsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database file
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// StartRun saves the metadata of a new run
+func (s *Store) StartRun(id string, started time.Time, options url.Values) error {
+	return s.putRun(Run{ID: id, Started: started, Options: options})
+}
+
+// FinishRun saves the end time and the report of a run
+func (s *Store) FinishRun(id string, rep crawler.Report) error {
+	run, err := s.Run(id)
+	if err != nil {
+		return err
+	}
+	run.Finished = rep.Finished
+	run.Report = rep
+	return s.putRun(run)
+}
+
+// putRun saves the run metadata under its id
+func (s *Store) putRun(run Run) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(runsBucket).Put([]byte(run.ID), data)
+	})
+}
+
+// SaveProduct saves a snapshot of the product fetched by the run
+// Products without an ASIN can not be tracked so they are rejected
+func (s *Store) SaveProduct(run string, prod crawler.Product, valid bool) error {
+	if prod.ASIN == "" {
+		return fmt.Errorf("product %s has no ASIN", prod.Link)
+	}
+	snap := Snapshot{Run: run, Time: time.Now().UTC(), Valid: valid, Product: prod}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	// Snapshot keys sort by time so the history of a product is read in order
+	key := timeKey(snap.Time, run)
+	return s.db.Update(func(tx *bolt.Tx) error {
+		prods, err := tx.Bucket(productsBucket).CreateBucketIfNotExists([]byte(prod.ASIN))
+		if err != nil {
+			return err
+		}
+		if err := prods.Put(key, data); err != nil {
+			return err
+		}
+		runProds, err := tx.Bucket(runProductsBucket).CreateBucketIfNotExists([]byte(run))
+		if err != nil {
+			return err
+		}
+		return runProds.Put([]byte(prod.ASIN), key)
+	})
+}
+
+// Run returns the metadata of a run
+func (s *Store) Run(id string) (Run, error) {
+	var run Run
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(runsBucket).Get([]byte(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(data, &run)
+	})
+	return run, err
+}
+
+// Runs returns the metadata of all the runs with the most recent first
+func (s *Store) Runs() ([]Run, error) {
+	runs := []Run{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(runsBucket).ForEach(func(k, v []byte) error {
+			var run Run
+			if err := json.Unmarshal(v, &run); err != nil {
+				return err
+			}
+			runs = append(runs, run)
+			return nil
+		})
+	})
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Started.After(runs[j].Started) })
+	return runs, err
+}
+
+// RunProducts returns the snapshots of all the products fetched by a run ordered by ASIN
+func (s *Store) RunProducts(id string) ([]Snapshot, error) {
+	snaps := []Snapshot{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(runsBucket).Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+		runProds := tx.Bucket(runProductsBucket).Bucket([]byte(id))
+		if runProds == nil {
+			return nil
+		}
+		prods := tx.Bucket(productsBucket)
+		return runProds.ForEach(func(asin, key []byte) error {
+			b := prods.Bucket(asin)
+			if b == nil {
+				return nil
+			}
+			var snap Snapshot
+			if err := json.Unmarshal(b.Get(key), &snap); err != nil {
+				return err
+			}
+			snaps = append(snaps, snap)
+			return nil
+		})
+	})
+	return snaps, err
+}
+
+// Snapshots returns all the snapshots of a product with the oldest first
+func (s *Store) Snapshots(asin string) ([]Snapshot, error) {
+	snaps := []Snapshot{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(productsBucket).Bucket([]byte(asin))
+		if b == nil {
+			return ErrNotFound
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var snap Snapshot
+			if err := json.Unmarshal(v, &snap); err != nil {
+				return err
+			}
+			snaps = append(snaps, snap)
+			return nil
+		})
+	})
+	return snaps, err
+}
@@ -0,0 +1,174 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/iulianclita/amazonsurfer/crawler"
+	bolt "go.etcd.io/bbolt"
+)
+
+// WatchRun is the run id of the snapshots taken by the watchlist refreshes
+const WatchRun = "watchlist"
+
+// watchBucket maps a watched ASIN to its watch item
+var watchBucket = []byte("watchlist")
+
+// ErrInvalidASIN is returned when adding something which is not an ASIN to the watchlist
+var ErrInvalidASIN = errors.New("invalid ASIN")
+
+// WatchItem is a product on the watchlist
+// Changes holds the fields which changed at the last refresh compared to the snapshot before it
+// Error holds the reason the last refresh failed
+type WatchItem struct {
+	ASIN    string        `json:"asin"`
+	Name    string        `json:"name"`
+	Link    string        `json:"link"`
+	Added   time.Time     `json:"added"`
+	Checked time.Time     `json:"checked"`
+	Changed bool          `json:"changed"`
+	Changes []FieldChange `json:"changes"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// Watch adds an ASIN to the watchlist, adding it again keeps the existing item
+func (s *Store) Watch(asin string) error {
+	if !crawler.ValidASIN(asin) {
+		return ErrInvalidASIN
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(watchBucket)
+		if err != nil {
+			return err
+		}
+		if b.Get([]byte(asin)) != nil {
+			return nil
+		}
+		return putWatch(b, WatchItem{ASIN: asin, Added: time.Now().UTC()})
+	})
+}
+
+// Unwatch removes an ASIN from the watchlist, its snapshots are kept
+func (s *Store) Unwatch(asin string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(watchBucket)
+		if b == nil || b.Get([]byte(asin)) == nil {
+			return ErrNotFound
+		}
+		return b.Delete([]byte(asin))
+	})
+}
+
+// Watchlist returns the watched products ordered by ASIN
+func (s *Store) Watchlist() ([]WatchItem, error) {
+	items := []WatchItem{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(watchBucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var item WatchItem
+			if err := json.Unmarshal(v, &item); err != nil {
+				return err
+			}
+			items = append(items, item)
+			return nil
+		})
+	})
+	return items, err
+}
+
+// Watched returns the watched ASINs
+func (s *Store) Watched() ([]string, error) {
+	items, err := s.Watchlist()
+	if err != nil {
+		return nil, err
+	}
+	asins := make([]string, len(items))
+	for i, item := range items {
+		asins[i] = item.ASIN
+	}
+	return asins, nil
+}
+
+// SaveWatched saves a snapshot of a watched product and flags the fields which changed since the last snapshot
+func (s *Store) SaveWatched(prod crawler.Product) error {
+	var changes []FieldChange
+	snaps, err := s.Snapshots(prod.ASIN)
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+	if len(snaps) > 0 {
+		changes = compare(&snaps[len(snaps)-1].Product, &prod)
+	}
+	if err := s.SaveProduct(WatchRun, prod, true); err != nil {
+		return err
+	}
+	return s.updateWatch(prod.ASIN, func(item *WatchItem) {
+		item.Name, item.Link = prod.Name, prod.Link
+		item.Checked = time.Now().UTC()
+		item.Changed = len(changes) > 0
+		item.Changes = changes
+		item.Error = ""
+	})
+}
+
+// LastCrawled returns the last snapshot of a product taken by a crawl along with the search input of that crawl
+// ErrNotFound is returned when only the watchlist ever fetched the product
+func (s *Store) LastCrawled(asin string) (crawler.Product, url.Values, error) {
+	snaps, err := s.Snapshots(asin)
+	if err != nil {
+		return crawler.Product{}, nil, err
+	}
+	for i := len(snaps) - 1; i >= 0; i-- {
+		if snaps[i].Run == WatchRun {
+			continue
+		}
+		run, err := s.Run(snaps[i].Run)
+		if err != nil && err != ErrNotFound {
+			return crawler.Product{}, nil, err
+		}
+		return snaps[i].Product, run.Options, nil
+	}
+	return crawler.Product{}, nil, ErrNotFound
+}
+
+// WatchFailed records why a watched product could not be fetched
+func (s *Store) WatchFailed(asin string, fetchErr error) error {
+	return s.updateWatch(asin, func(item *WatchItem) {
+		item.Checked = time.Now().UTC()
+		item.Error = fetchErr.Error()
+	})
+}
+
+// updateWatch changes a watch item, items removed in the meantime are left alone
+func (s *Store) updateWatch(asin string, update func(item *WatchItem)) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(watchBucket)
+		if b == nil {
+			return nil
+		}
+		data := b.Get([]byte(asin))
+		if data == nil {
+			return nil
+		}
+		var item WatchItem
+		if err := json.Unmarshal(data, &item); err != nil {
+			return err
+		}
+		update(&item)
+		return putWatch(b, item)
+	})
+}
+
+// putWatch saves a watch item under its ASIN
+func putWatch(b *bolt.Bucket, item WatchItem) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(item.ASIN), data)
+}
@@ -0,0 +1,181 @@
+package store
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// These are the buckets of the webhooks and their deliveries
+var (
+	// webhooksBucket maps a webhook id to the webhook
+	webhooksBucket = []byte("webhooks")
+	// deliveriesBucket maps a time ordered key to a delivery
+	deliveriesBucket = []byte("deliveries")
+)
+
+// Webhook is an outgoing HTTP callback the events are posted to
+// Secret signs the payloads with HMAC SHA256 and Events lists the event types wanted, all of them when empty
+type Webhook struct {
+	ID      string   `json:"id"`
+	URL     string   `json:"url"`
+	Secret  string   `json:"secret"`
+	Events  []string `json:"events"`
+	Enabled bool     `json:"enabled"`
+}
+
+// Wants tells if the webhook is interested in an event type
+func (h Webhook) Wants(typ string) bool {
+	if len(h.Events) == 0 {
+		return true
+	}
+	for _, e := range h.Events {
+		if e == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery records the outcome of posting an event to a webhook
+// Status is the last HTTP status received and Error the reason the last attempt failed
+type Delivery struct {
+	ID        string    `json:"id"`
+	Webhook   string    `json:"webhook"`
+	Event     string    `json:"event"`
+	Time      time.Time `json:"time"`
+	Attempts  int       `json:"attempts"`
+	Status    int       `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Delivered bool      `json:"delivered"`
+}
+
+// SaveWebhook creates or updates a webhook, a new webhook gets an id
+// The secrets are never sent back to the clients so updating a webhook without a secret keeps the stored one,
+// unless clearSecret tells to remove it
+func (s *Store) SaveWebhook(h Webhook, clearSecret bool) (Webhook, error) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(webhooksBucket)
+		if err != nil {
+			return err
+		}
+		if h.ID == "" {
+			seq, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			h.ID = strconv.FormatUint(seq, 10)
+		} else {
+			data := b.Get([]byte(h.ID))
+			if data == nil {
+				return ErrNotFound
+			}
+			var old Webhook
+			if err := json.Unmarshal(data, &old); err != nil {
+				return err
+			}
+			if h.Secret == "" && !clearSecret {
+				h.Secret = old.Secret
+			}
+		}
+		data, err := json.Marshal(h)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(h.ID), data)
+	})
+	return h, err
+}
+
+// DeleteWebhook removes a webhook, its deliveries are kept
+func (s *Store) DeleteWebhook(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(webhooksBucket)
+		if b == nil || b.Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+		return b.Delete([]byte(id))
+	})
+}
+
+// Webhook returns a webhook
+func (s *Store) Webhook(id string) (Webhook, error) {
+	var h Webhook
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(webhooksBucket)
+		if b == nil {
+			return ErrNotFound
+		}
+		data := b.Get([]byte(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(data, &h)
+	})
+	return h, err
+}
+
+// Webhooks returns all the webhooks
+func (s *Store) Webhooks() ([]Webhook, error) {
+	hooks := []Webhook{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(webhooksBucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var h Webhook
+			if err := json.Unmarshal(v, &h); err != nil {
+				return err
+			}
+			hooks = append(hooks, h)
+			return nil
+		})
+	})
+	return hooks, err
+}
+
+// SaveDelivery records a delivery giving it an id
+func (s *Store) SaveDelivery(d Delivery) (Delivery, error) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(deliveriesBucket)
+		if err != nil {
+			return err
+		}
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		d.ID = strconv.FormatUint(seq, 10)
+		data, err := json.Marshal(d)
+		if err != nil {
+			return err
+		}
+		// The time starts the key so the deliveries are sorted by the time they were made
+		return b.Put(timeKey(d.Time, d.ID), data)
+	})
+	return d, err
+}
+
+// Deliveries returns the most recent deliveries first, at most limit of them
+func (s *Store) Deliveries(limit int) ([]Delivery, error) {
+	deliveries := []Delivery{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(deliveriesBucket)
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Last(); k != nil && len(deliveries) < limit; k, v = c.Prev() {
+			var d Delivery
+			if err := json.Unmarshal(v, &d); err != nil {
+				return err
+			}
+			deliveries = append(deliveries, d)
+		}
+		return nil
+	})
+	return deliveries, err
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/iulianclita/amazonsurfer/crawler"
+	"github.com/iulianclita/amazonsurfer/store"
+)
+
+// watchlist lists the watched products on GET, adds an ASIN on POST and removes one on DELETE
+func watchlist(w http.ResponseWriter, r *http.Request) {
+	if db == nil {
+		http.Error(w, "Storage is disabled", http.StatusNotFound)
+		return
+	}
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		items, err := db.Watchlist()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, items)
+		return
+	case http.MethodPost:
+		if err = db.Watch(crawler.NormalizeASIN(r.FormValue("asin"))); err == nil {
+			// Fetch the new product without waiting for the next refresh
+			watcher.RefreshNow()
+		}
+	case http.MethodDelete:
+		err = db.Unwatch(crawler.NormalizeASIN(r.FormValue("asin")))
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	switch err {
+	case nil:
+		writeJSON(w, http.StatusOK, "ok")
+	case store.ErrInvalidASIN:
+		http.Error(w, "Invalid ASIN", http.StatusBadRequest)
+	case store.ErrNotFound:
+		http.Error(w, "ASIN not watched", http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/iulianclita/amazonsurfer/crawler"
+	"github.com/iulianclita/amazonsurfer/notify"
+	"github.com/iulianclita/amazonsurfer/store"
+)
+
+// webhooksList lists the webhooks on GET, saves one on POST and removes one on DELETE
+// A webhook is posted as a form (events comma separated) or as a JSON body, posting it with an id updates it
+func webhooksList(w http.ResponseWriter, r *http.Request) {
+	if db == nil {
+		http.Error(w, "Storage is disabled", http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		hooks, err := db.Webhooks()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		list := make([]webhookResponse, len(hooks))
+		for i, h := range hooks {
+			list[i] = newWebhookResponse(h)
+		}
+		writeJSON(w, http.StatusOK, list)
+	case http.MethodPost:
+		h, clearSecret, err := parseWebhook(r)
+		if err != nil {
+			writeErrors(w, err)
+			return
+		}
+		h, err = db.SaveWebhook(h, clearSecret)
+		if err == store.ErrNotFound {
+			http.Error(w, "Webhook not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, newWebhookResponse(h))
+	case http.MethodDelete:
+		err := db.DeleteWebhook(r.FormValue("id"))
+		if err == store.ErrNotFound {
+			http.Error(w, "Webhook not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, "ok")
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// webhookResponse is a webhook as sent back to clients
+// The secret signing the payloads is never sent, HasSecret only tells if the webhook has one
+type webhookResponse struct {
+	ID        string   `json:"id"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	Enabled   bool     `json:"enabled"`
+	HasSecret bool     `json:"hasSecret"`
+}
+
+// newWebhookResponse hides the secret of a webhook
+func newWebhookResponse(h store.Webhook) webhookResponse {
+	return webhookResponse{ID: h.ID, URL: h.URL, Events: h.Events, Enabled: h.Enabled, HasSecret: h.Secret != ""}
+}
+
+// parseWebhook reads and checks a webhook from a JSON body or a form
+// Webhooks are enabled unless the enabled field says otherwise
+// clearSecret tells to remove the secret of an updated webhook, it is the clear-secret field (clearSecret in JSON)
+func parseWebhook(r *http.Request) (h store.Webhook, clearSecret bool, err error) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		body := struct {
+			store.Webhook
+			ClearSecret bool `json:"clearSecret"`
+		}{Webhook: store.Webhook{Enabled: true}}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return h, false, crawler.ValidationError{{Field: "body", Message: err.Error()}}
+		}
+		h, clearSecret = body.Webhook, body.ClearSecret
+	} else {
+		h = store.Webhook{
+			ID:      r.FormValue("id"),
+			URL:     strings.TrimSpace(r.FormValue("url")),
+			Secret:  r.FormValue("secret"),
+			Events:  splitEvents(r.Form["events"]),
+			Enabled: r.FormValue("enabled") != "false",
+		}
+		clearSecret = r.FormValue("clear-secret") != "" && r.FormValue("clear-secret") != "false"
+	}
+	var errs crawler.ValidationError
+	if clearSecret && h.Secret != "" {
+		errs = append(errs, crawler.FieldError{Field: "clear-secret", Message: "cannot be used along with a secret"})
+	}
+	if u, err := url.Parse(h.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, crawler.FieldError{Field: "url", Message: "must be an absolute http or https URL"})
+	}
+	for _, e := range h.Events {
+		known := false
+		for _, t := range notify.EventTypes {
+			known = known || e == t
+		}
+		if !known {
+			errs = append(errs, crawler.FieldError{Field: "events", Message: "has an unknown event type " + e})
+		}
+	}
+	if len(errs) > 0 {
+		return h, clearSecret, errs
+	}
+	return h, clearSecret, nil
+}
+
+// splitEvents accepts the events as repeated values and as comma separated lists
+func splitEvents(values []string) []string {
+	var events []string
+	for _, v := range values {
+		for _, e := range strings.Split(v, ",") {
+			if e = strings.TrimSpace(e); e != "" {
+				events = append(events, e)
+			}
+		}
+	}
+	return events
+}
+
+// webhookTest sends a ping event to a webhook right away and answers with the delivery
+func webhookTest(w http.ResponseWriter, r *http.Request) {
+	if db == nil {
+		http.Error(w, "Storage is disabled", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	h, err := db.Webhook(r.FormValue("id"))
+	if err == store.ErrNotFound {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, webhooks.Deliver(h, notify.NewEvent(notify.EventPing, "ping")))
+}
+
+// deliveries sends the most recent webhook deliveries, 100 of them unless a limit is given
+func deliveries(w http.ResponseWriter, r *http.Request) {
+	if db == nil {
+		http.Error(w, "Storage is disabled", http.StatusNotFound)
+		return
+	}
+	limit, ok := parseLimit(w, r)
+	if !ok {
+		return
+	}
+	ds, err := db.Deliveries(limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, ds)
+}